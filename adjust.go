@@ -0,0 +1,223 @@
+package himage
+
+import (
+	"errors"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Brightness changes the brightness by percentage in -100..100
+func (i *Himage) Brightness(percentage float64) *Himage {
+	if percentage < -100 || percentage > 100 {
+		i.Error = errors.New("brightness must be between -100 and 100")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustBrightness(src, percentage)
+	})
+}
+
+// Contrast changes the contrast by percentage in -100..100
+func (i *Himage) Contrast(percentage float64) *Himage {
+	if percentage < -100 || percentage > 100 {
+		i.Error = errors.New("contrast must be between -100 and 100")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustContrast(src, percentage)
+	})
+}
+
+// Gamma applies gamma correction, values below 1 darken the image
+func (i *Himage) Gamma(gamma float64) *Himage {
+	if gamma <= 0 || gamma > 10 {
+		i.Error = errors.New("gamma must be between 0 and 10")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustGamma(src, gamma)
+	})
+}
+
+// Saturation changes the saturation by percentage in -100..100
+func (i *Himage) Saturation(percentage float64) *Himage {
+	if percentage < -100 || percentage > 100 {
+		i.Error = errors.New("saturation must be between -100 and 100")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustSaturation(src, percentage)
+	})
+}
+
+// Hue rotates the hue by degrees in -180..180
+func (i *Himage) Hue(degrees float64) *Himage {
+	if degrees < -180 || degrees > 180 {
+		i.Error = errors.New("hue shift must be between -180 and 180")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustFunc(src, func(c color.NRGBA) color.NRGBA {
+			h, s, l := rgbToHSL(c)
+			h = math.Mod(h+degrees/360+1, 1)
+			r, g, b := hslToRGB(h, s, l)
+			return color.NRGBA{R: r, G: g, B: b, A: c.A}
+		})
+	})
+}
+
+// Grayscale ..
+func (i *Himage) Grayscale() *Himage {
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.Grayscale(src)
+	})
+}
+
+// Sepia ..
+func (i *Himage) Sepia() *Himage {
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.AdjustFunc(src, func(c color.NRGBA) color.NRGBA {
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+			return color.NRGBA{
+				R: clamp(0.393*r + 0.769*g + 0.189*b),
+				G: clamp(0.349*r + 0.686*g + 0.168*b),
+				B: clamp(0.272*r + 0.534*g + 0.131*b),
+				A: c.A,
+			}
+		})
+	})
+}
+
+// Invert ..
+func (i *Himage) Invert() *Himage {
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.Invert(src)
+	})
+}
+
+// Blur applies a Gaussian blur of sigma
+func (i *Himage) Blur(sigma float64) *Himage {
+	if sigma <= 0 || sigma > 100 {
+		i.Error = errors.New("blur sigma must be between 0 and 100")
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.Blur(src, sigma)
+	})
+}
+
+// Sharpen applies an unsharp mask
+func (i *Himage) Sharpen(option Sharpen) *Himage {
+	if err := option.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return unsharp(src, option)
+	})
+}
+
+// unsharp adds the difference to a blurred copy, scaled by Amount, where
+// it reaches Threshold. Alpha is kept.
+func unsharp(src image.Image, option Sharpen) *image.NRGBA {
+	amount := option.Amount
+	if amount == 0 {
+		amount = 1
+	}
+
+	dst := imaging.Clone(src)
+	blurred := imaging.Blur(dst, option.Sigma)
+	for k := 0; k < len(dst.Pix); k += 4 {
+		for c := k; c < k+3; c++ {
+			diff := float64(dst.Pix[c]) - float64(blurred.Pix[c])
+			if math.Abs(diff) < option.Threshold {
+				continue
+			}
+			dst.Pix[c] = clamp(float64(dst.Pix[c]) + amount*diff)
+		}
+	}
+
+	return dst
+}
+
+// clamp rounds v into a color channel
+func clamp(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// rgbToHSL returns hue, saturation and lightness in 0..1
+func rgbToHSL(c color.NRGBA) (float64, float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+
+	d := max - min
+	s := d / (max + min)
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	}
+
+	var h float64
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+
+	return h / 6, s, l
+}
+
+// hslToRGB ..
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	if s == 0 {
+		v := clamp(l * 255)
+		return v, v, v
+	}
+
+	q := l * (1 + s)
+	if l >= 0.5 {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	return clamp(hueToRGB(p, q, h+1.0/3) * 255), clamp(hueToRGB(p, q, h) * 255), clamp(hueToRGB(p, q, h-1.0/3) * 255)
+}
+
+// hueToRGB ..
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t++
+	}
+	if t > 1 {
+		t--
+	}
+
+	switch {
+	case t < 1.0/6:
+		return p + (q-p)*6*t
+	case t < 1.0/2:
+		return q
+	case t < 2.0/3:
+		return p + (q-p)*(2.0/3-t)*6
+	}
+	return p
+}
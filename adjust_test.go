@@ -0,0 +1,148 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageAdjust(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "10x10.png")).
+		Brightness(10).
+		Contrast(-10).
+		Gamma(1.2).
+		Saturation(20).
+		Hue(90).
+		Sepia().
+		Invert().
+		Blur(1).
+		Sharpen(Sharpen{Sigma: 1, Amount: 1.5}).
+		Grayscale()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	im, err := hImage.image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := color.NRGBAModel.Convert(im.At(5, 5)).(color.NRGBA)
+	if c.R != c.G || c.G != c.B {
+		t.Error(errors.New("grayscale is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "10x10.png")).Brightness(101)
+	if hImage.Error == nil {
+		t.Error(errors.New("brightness out of range should fail"))
+	}
+}
+
+func TestHue(t *testing.T) {
+	for _, c := range []color.NRGBA{{R: 255, A: 255}, {R: 30, G: 160, B: 90, A: 255}, {R: 128, G: 128, B: 128, A: 255}} {
+		h, s, l := rgbToHSL(c)
+		r, g, b := hslToRGB(h, s, l)
+		if r != c.R || g != c.G || b != c.B {
+			t.Error(errors.New("hsl round trip is not valid"))
+		}
+	}
+
+	h, _, _ := rgbToHSL(color.NRGBA{G: 255, A: 255})
+	if h*360 < 119 || h*360 > 121 {
+		t.Error(errors.New("hue is not valid"))
+	}
+}
+
+func TestResizeSharpen(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 20; x < 40; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	out := unsharp(src, Sharpen{Sigma: 2, Amount: 1})
+	if out.NRGBAAt(18, 10).R != 0 || out.NRGBAAt(21, 10).R != 255 {
+		t.Error(errors.New("unsharp mask is not valid"))
+	}
+
+	if (Resize{Width: 10, Sharpen: Sharpen{Amount: 1}}).Valid() == nil {
+		t.Error(errors.New("sharpen without sigma should fail"))
+	}
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+		Resize(Resize{Width: 320, Sharpen: Sharpen{Sigma: 0.5, Amount: 0.8, Threshold: 2}})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Width != 320 {
+		t.Error(errors.New("resolution is not valid"))
+	}
+}
+
+func TestResizeDefaultSharpen(t *testing.T) {
+	sharpness := func(name string, option Resize) float64 {
+		hImage := NewHimageWithPath(filepath.Join("test-files", name)).Resize(option)
+		if hImage.Error != nil {
+			t.Fatal(hImage.Error)
+		}
+		defer hImage.Finish()
+
+		im, err := hImage.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, h := im.Bounds().Dx(), im.Bounds().Dy()
+		return laplacianVariance(grayPixels(im, w, h), w, h)
+	}
+
+	if sharpness("640x426.jpeg", Resize{Width: 320}) <= sharpness("640x426.jpeg", Resize{Width: 320, NoSharpen: true}) {
+		t.Error(errors.New("downscale should be sharpened by default"))
+	}
+
+	if sharpness("120x60.svg", Resize{Width: 60}) <= sharpness("120x60.svg", Resize{Width: 60, NoSharpen: true}) {
+		t.Error(errors.New("svg downscale should be sharpened by default"))
+	}
+
+	if (Resize{Width: 10, NoSharpen: true, Sharpen: Sharpen{Sigma: 1}}).Valid() == nil {
+		t.Error(errors.New("sharpen with no sharpen should fail"))
+	}
+
+	_, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "resize", "width": 10, "sigma": 1, "no_sharpen": true}]}`))
+	if e, ok := err.(*PipelineError); !ok || e.Path != "steps[0].no_sharpen" {
+		t.Error(errors.New("no sharpen with sigma should fail"))
+	}
+}
+
+func TestPipelineAdjustSteps(t *testing.T) {
+	p, err := ParsePipelineJSON([]byte(`{"steps": [
+		{"op": "resize", "width": 100, "sigma": 0.5},
+		{"op": "hue", "angle": 30},
+		{"op": "sharpen", "sigma": 1, "amount": 2},
+		{"op": "grayscale"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hImage := p.Apply(NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")))
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	hImage.Finish()
+
+	_, err = ParsePipelineJSON([]byte(`{"steps": [{"op": "blur", "percentage": 10}]}`))
+	if e, ok := err.(*PipelineError); !ok || e.Path != "steps[0].percentage" {
+		t.Error(errors.New("blur percentage should fail"))
+	}
+
+	_, err = ParsePipelineJSON([]byte(`{"steps": [{"op": "gamma"}]}`))
+	if e, ok := err.(*PipelineError); !ok || e.Path != "steps[0].gamma" {
+		t.Error(errors.New("missing gamma should fail"))
+	}
+}
@@ -0,0 +1,118 @@
+package himage
+
+import (
+	"github.com/disintegration/imaging"
+	"math"
+)
+
+// Analysis reports exposure and sharpness statistics of an image
+type Analysis struct {
+	// Red, Green, Blue and Luma are 256 bin histograms of the opaque pixels
+	Red   [256]int
+	Green [256]int
+	Blue  [256]int
+	Luma  [256]int
+	// Luminance is the mean luma between 0 and 1
+	Luminance float64
+	// Contrast is the luma standard deviation in 0-255
+	Contrast float64
+	// Shadows is the share of pixels clipped to black
+	Shadows float64
+	// Highlights is the share of pixels clipped to white
+	Highlights float64
+	// Sharpness is the variance of the Laplacian, low values mean blur
+	Sharpness float64
+	// Blurry is set when Sharpness is under BLUR_THRESHOLD
+	Blurry bool
+	// Flat is set when Contrast is under FLAT_THRESHOLD
+	Flat bool
+	// Underexposed is set for dark images or more than 10% clipped shadows
+	Underexposed bool
+	// Overexposed is set for bright images or more than 10% clipped highlights
+	Overexposed bool
+}
+
+// Analyze fills Detail.Analysis from a copy downsampled to ANALYZE_SIZE,
+// the first frame of animations
+func (i *Himage) Analyze() *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	src, err := i.image()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	im := imaging.Fit(src, ANALYZE_SIZE, ANALYZE_SIZE, imaging.Box)
+	w, h := im.Bounds().Dx(), im.Bounds().Dy()
+	a := new(Analysis)
+
+	luma := make([]float64, w*h)
+	n, sum, squares := 0, 0.0, 0.0
+	shadows, highlights := 0, 0
+	for k := range luma {
+		r, g, b := im.Pix[k*4], im.Pix[k*4+1], im.Pix[k*4+2]
+		y := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+		luma[k] = y
+		if im.Pix[k*4+3] < 128 {
+			continue
+		}
+
+		a.Red[r]++
+		a.Green[g]++
+		a.Blue[b]++
+		a.Luma[clamp(y)]++
+
+		n++
+		sum += y
+		squares += y * y
+		if y <= 2 {
+			shadows++
+		}
+		if y >= 253 {
+			highlights++
+		}
+	}
+
+	if n > 0 {
+		mean := sum / float64(n)
+		a.Luminance = mean / 255
+		a.Contrast = math.Sqrt(math.Max(0, squares/float64(n)-mean*mean))
+		a.Shadows = float64(shadows) / float64(n)
+		a.Highlights = float64(highlights) / float64(n)
+	}
+
+	a.Sharpness = laplacianVariance(luma, w, h)
+	a.Blurry = a.Sharpness < BLUR_THRESHOLD
+	a.Flat = a.Contrast < FLAT_THRESHOLD
+	a.Underexposed = n > 0 && (a.Luminance < 0.15 || a.Shadows > 0.1)
+	a.Overexposed = n > 0 && (a.Luminance > 0.85 || a.Highlights > 0.1)
+
+	i.Detail.Analysis = a
+
+	return i
+}
+
+// laplacianVariance is the variance of the 4-neighbour Laplacian of a
+// grayscale image
+func laplacianVariance(gray []float64, w, h int) float64 {
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	n, sum, squares := 0, 0.0, 0.0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			k := y*w + x
+			l := 4*gray[k] - gray[k-1] - gray[k+1] - gray[k-w] - gray[k+w]
+			n++
+			sum += l
+			squares += l * l
+		}
+	}
+
+	mean := sum / float64(n)
+	return squares/float64(n) - mean*mean
+}
@@ -0,0 +1,61 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageAnalyze(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).Analyze()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	a := hImage.Detail.Analysis
+	if a == nil {
+		t.Fatal(errors.New("analysis is nil"))
+	}
+
+	total := 0
+	for _, count := range a.Luma {
+		total += count
+	}
+	if total != 512*341 {
+		t.Error(errors.New("histogram is not valid"))
+	}
+
+	if a.Luminance <= 0 || a.Luminance >= 1 || a.Flat {
+		t.Error(errors.New("luminance is not valid"))
+	}
+
+	sharp := a.Sharpness
+	hImage = NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).Blur(8).Analyze()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if !hImage.Detail.Analysis.Blurry || hImage.Detail.Analysis.Sharpness >= sharp {
+		t.Error(errors.New("blur score is not valid"))
+	}
+}
+
+func TestHimageAnalyzeFlat(t *testing.T) {
+	f := writeTestImage(t, func(im *image.NRGBA) {
+		draw.Draw(im, im.Bounds(), image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: 255}), image.Point{}, draw.Src)
+	})
+
+	hImage := NewHimageWithPath(f).Analyze()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	a := hImage.Detail.Analysis
+	if !a.Flat || !a.Overexposed || a.Underexposed || a.Highlights != 1 || a.Red[255] != 1600 {
+		t.Error(errors.New("flat analysis is not valid"))
+	}
+}
@@ -0,0 +1,247 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// ErrBatchCanceled is reported for items skipped after a fail-fast stop
+var ErrBatchCanceled = errors.New("batch canceled before the item was processed")
+
+// Batch processes many image sources on a bounded worker pool
+type Batch struct {
+	// Workers is the number of concurrent workers, defaults to runtime.NumCPU
+	Workers int
+	// MemoryBudget is the estimated decoded bytes allowed in flight, zero means unlimited
+	MemoryBudget int64
+	// FailFast stops scheduling new items after the first failure
+	FailFast bool
+	// Destination is applied to every item with SetDestination when not empty
+	Destination string
+	// Pipeline is applied to every item, Batch calls Finish itself so the
+	// pipeline must not
+	Pipeline func(i *Himage) *Himage
+}
+
+// BatchResult is the outcome of a single batch item
+type BatchResult struct {
+	Index  int
+	Source string
+	Output string
+	Image  *Himage
+	Error  error
+}
+
+// BatchError reports the failed items of a batch run
+type BatchError struct {
+	Failed []BatchResult
+}
+
+// Error ..
+func (e *BatchError) Error() string {
+	first := e.Failed[0]
+	return fmt.Sprintf("%d batch item(s) failed, first %s: %s", len(e.Failed), first.Source, first.Error)
+}
+
+// NewBatch ..
+func NewBatch(workers int, pipeline func(i *Himage) *Himage) *Batch {
+	return &Batch{
+		Workers:  workers,
+		Pipeline: pipeline,
+	}
+}
+
+// Run processes the given paths, results are ordered as the sources
+func (b *Batch) Run(sources []string) ([]BatchResult, error) {
+	n := 0
+	return b.run(func() (string, bool) {
+		if n >= len(sources) {
+			return "", false
+		}
+		n++
+		return sources[n-1], true
+	})
+}
+
+// RunChan processes paths received from the channel until it is closed,
+// results are ordered as the sources were received. After a FailFast stop
+// the channel is still drained so senders are not blocked, the remaining
+// paths are reported as ErrBatchCanceled.
+func (b *Batch) RunChan(sources <-chan string) ([]BatchResult, error) {
+	return b.run(func() (string, bool) {
+		source, ok := <-sources
+		return source, ok
+	})
+}
+
+// batchJob ..
+type batchJob struct {
+	index  int
+	source string
+}
+
+// run ..
+func (b *Batch) run(next func() (string, bool)) ([]BatchResult, error) {
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	memory := newMemoryBudget(b.MemoryBudget)
+	jobs := make(chan batchJob)
+	stop := make(chan struct{})
+	var once sync.Once
+
+	var mu sync.Mutex
+	results := make([]BatchResult, 0)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				r := b.process(job, memory, stop)
+				if r.Error != nil && r.Error != ErrBatchCanceled && b.FailFast {
+					once.Do(func() {
+						close(stop)
+					})
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// sources are consumed to the end even after a stop
+	for index := 0; ; index++ {
+		source, ok := next()
+		if !ok {
+			break
+		}
+
+		select {
+		case <-stop:
+			mu.Lock()
+			results = append(results, BatchResult{Index: index, Source: source, Error: ErrBatchCanceled})
+			mu.Unlock()
+		case jobs <- batchJob{index: index, source: source}:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(x, y int) bool {
+		return results[x].Index < results[y].Index
+	})
+
+	failed := make([]BatchResult, 0)
+	for _, r := range results {
+		if r.Error != nil && r.Error != ErrBatchCanceled {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &BatchError{Failed: failed}
+	}
+
+	return results, nil
+}
+
+// process ..
+func (b *Batch) process(job batchJob, memory *memoryBudget, stop <-chan struct{}) (r BatchResult) {
+	r.Index = job.index
+	r.Source = job.source
+
+	select {
+	case <-stop:
+		r.Error = ErrBatchCanceled
+		return r
+	default:
+	}
+
+	// the temp file is removed by Finish, or here after a panic skipped it
+	var i *Himage
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.Error = fmt.Errorf("batch item panicked: %v", rec)
+		}
+		if i != nil && i.tempPath != "" {
+			os.Remove(i.tempPath)
+		}
+	}()
+
+	i = NewHimageWithPath(job.source)
+	if b.Destination != "" {
+		i.SetDestination(b.Destination)
+	}
+
+	if i.Error == nil && b.Pipeline != nil {
+		cost := i.memoryCost()
+		memory.acquire(cost)
+		defer memory.release(cost)
+
+		if p := b.Pipeline(i); p != nil {
+			i = p
+		}
+	}
+
+	_, err := i.Finish()
+	r.Image = i
+	r.Output = i.Output()
+	r.Error = err
+
+	return r
+}
+
+// memoryCost estimates decoded bytes of the source and a transformed copy
+func (i *Himage) memoryCost() int64 {
+	return int64(i.Detail.Width) * int64(i.Detail.Height) * 4 * 2
+}
+
+// memoryBudget is a weighted semaphore over estimated decoded bytes
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// newMemoryBudget ..
+func newMemoryBudget(limit int64) *memoryBudget {
+	m := &memoryBudget{limit: limit}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// acquire blocks until cost fits in the budget. An item larger than the
+// whole budget runs alone instead of blocking forever.
+func (m *memoryBudget) acquire(cost int64) {
+	if m.limit <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	for m.used > 0 && m.used+cost > m.limit {
+		m.cond.Wait()
+	}
+	m.used += cost
+	m.mu.Unlock()
+}
+
+// release ..
+func (m *memoryBudget) release(cost int64) {
+	if m.limit <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	m.used -= cost
+	m.cond.Broadcast()
+	m.mu.Unlock()
+}
@@ -0,0 +1,270 @@
+package himage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func resizePipeline(i *Himage) *Himage {
+	return i.Resize(Resize{Width: 100, Height: 100})
+}
+
+func TestBatchRun(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := []string{
+		filepath.Join("test-files", "850x566.png"),
+		filepath.Join("test-files", "640x426.jpeg"),
+		filepath.Join("test-files", "10x10.png"),
+	}
+
+	batch := NewBatch(2, resizePipeline)
+	batch.Destination = dst
+	results, err := batch.Run(sources)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(results) != len(sources) {
+		t.Fatal(errors.New("result count is not valid"))
+	}
+
+	for index, r := range results {
+		if r.Index != index || r.Source != sources[index] {
+			t.Error(errors.New("result order is not valid"))
+		}
+
+		if r.Error != nil {
+			t.Error(r.Error)
+			continue
+		}
+
+		o := NewHimageWithPath(r.Output)
+		if o.Error != nil {
+			t.Error(o.Error)
+		}
+
+		if o.Detail.Width != 100 || o.Detail.Height != 100 {
+			t.Error(errors.New("output resolution is not valid"))
+		}
+	}
+
+	if _, err := os.Stat(sources[0]); err != nil {
+		t.Error(errors.New("origin should not be removed"))
+	}
+}
+
+func TestBatchRunContinueOnError(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := []string{
+		filepath.Join("test-files", "notfound.png"),
+		filepath.Join("test-files", "10x10.png"),
+	}
+
+	batch := NewBatch(1, resizePipeline)
+	batch.Destination = dst
+	results, err := batch.Run(sources)
+
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatal(errors.New("batch error is not valid"))
+	}
+
+	if len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 0 {
+		t.Error(errors.New("failed items are not valid"))
+	}
+
+	if results[1].Error != nil {
+		t.Error(results[1].Error)
+	}
+}
+
+func TestBatchRunFailFast(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := []string{
+		filepath.Join("test-files", "notfound.png"),
+		filepath.Join("test-files", "10x10.png"),
+		filepath.Join("test-files", "850x566.png"),
+	}
+
+	batch := NewBatch(1, resizePipeline)
+	batch.Destination = dst
+	batch.FailFast = true
+	results, err := batch.Run(sources)
+	if err == nil {
+		t.Error(errors.New("fail fast error is nil"))
+	}
+
+	if len(results) != len(sources) {
+		t.Fatal(errors.New("result count is not valid"))
+	}
+
+	if results[2].Error != ErrBatchCanceled {
+		t.Error(errors.New("remaining items should be canceled"))
+	}
+}
+
+func TestBatchRunChan(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := make(chan string, 2)
+	sources <- filepath.Join("test-files", "10x10.png")
+	sources <- filepath.Join("test-files", "850x566.png")
+	close(sources)
+
+	batch := NewBatch(2, resizePipeline)
+	batch.Destination = dst
+	batch.MemoryBudget = 1
+	results, err := batch.RunChan(sources)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(results) != 2 || results[0].Index != 0 || results[1].Index != 1 {
+		t.Error(errors.New("result order is not valid"))
+	}
+}
+
+func TestBatchRunChanFailFast(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := make(chan string)
+	sent := make(chan bool)
+	go func() {
+		sources <- filepath.Join("test-files", "notfound.png")
+		for n := 0; n < 5; n++ {
+			sources <- filepath.Join("test-files", "10x10.png")
+		}
+		close(sources)
+		sent <- true
+	}()
+
+	batch := NewBatch(1, resizePipeline)
+	batch.Destination = dst
+	batch.FailFast = true
+	results, err := batch.RunChan(sources)
+	if err == nil {
+		t.Error(errors.New("fail fast error is nil"))
+	}
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal(errors.New("sources should be drained after a stop"))
+	}
+
+	if len(results) != 6 || results[5].Error != ErrBatchCanceled {
+		t.Error(errors.New("drained items should be canceled"))
+	}
+}
+
+func TestBatchRunMemoryBudget(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	sources := make([]string, 6)
+	for n := range sources {
+		sources[n] = filepath.Join("test-files", "10x10.png")
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	batch := NewBatch(len(sources), func(i *Himage) *Himage {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return i
+	})
+	batch.Destination = dst
+	batch.MemoryBudget = 10 * 10 * 4 * 2
+	if _, err := batch.Run(sources); err != nil {
+		t.Fatal(err)
+	}
+
+	if peak != 1 {
+		t.Errorf("%d items ran within a single item budget", peak)
+	}
+}
+
+func Test_memoryBudget(t *testing.T) {
+	m := newMemoryBudget(100)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	running, peak := 0, 0
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.acquire(60)
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			m.release(60)
+		}()
+	}
+	wg.Wait()
+
+	if peak != 1 {
+		t.Error(errors.New("budget exceeded"))
+	}
+
+	if m.used != 0 {
+		t.Error(errors.New("budget is not released"))
+	}
+
+	// an item larger than the budget still runs
+	m.acquire(200)
+	m.release(200)
+}
+
+func TestBatchRunPanic(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	var temp string
+	batch := NewBatch(1, func(i *Himage) *Himage {
+		i.Resize(Resize{Width: 5, Height: 5})
+		temp = i.tempPath
+		panic("broken pipeline")
+	})
+	batch.Destination = dst
+	results, err := batch.Run([]string{filepath.Join("test-files", "10x10.png")})
+	if err == nil || results[0].Error == nil {
+		t.Fatal(errors.New("panic should fail the item"))
+	}
+
+	if _, err := os.Stat(temp); temp == "" || !os.IsNotExist(err) {
+		t.Error(errors.New("temp file should be removed after a panic"))
+	}
+}
@@ -0,0 +1,227 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"math"
+	"strings"
+)
+
+// blurHashAlphabet is the base 83 alphabet of BlurHash
+const blurHashAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash encodes the current image as a BlurHash with x and y components
+// in 1..9, the first frame of animations
+func (i *Himage) BlurHash(xComponents, yComponents int) (string, error) {
+	if i.Error != nil {
+		return "", i.Error
+	}
+
+	src, err := i.image()
+	if err != nil {
+		return "", err
+	}
+
+	return EncodeBlurHash(src, xComponents, yComponents)
+}
+
+// EncodeBlurHash encodes an image as a BlurHash, it is computed on a copy
+// at most 64 pixels wide
+func EncodeBlurHash(src image.Image, xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", errors.New("blurhash components must be between 1 and 9")
+	}
+
+	im := imaging.Clone(src)
+	if im.Bounds().Dx() > 64 {
+		im = imaging.Resize(im, 64, 0, imaging.Box)
+	}
+	w, h := im.Bounds().Dx(), im.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return "", errors.New("blurhash image is empty")
+	}
+
+	linear := make([][3]float64, w*h)
+	for k := range linear {
+		for c := 0; c < 3; c++ {
+			linear[k][c] = sRGBToLinear(im.Pix[k*4+c])
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for y := 0; y < yComponents; y++ {
+		for x := 0; x < xComponents; x++ {
+			normalization := 2.0
+			if x == 0 && y == 0 {
+				normalization = 1
+			}
+
+			var factor [3]float64
+			for py := 0; py < h; py++ {
+				cy := math.Cos(math.Pi * float64(y) * float64(py) / float64(h))
+				for px := 0; px < w; px++ {
+					basis := normalization * cy * math.Cos(math.Pi*float64(x)*float64(px)/float64(w))
+					for c := 0; c < 3; c++ {
+						factor[c] += basis * linear[py*w+px][c]
+					}
+				}
+			}
+
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	hash := new(strings.Builder)
+	encode83(hash, (xComponents-1)+(yComponents-1)*9, 1)
+
+	maximum := 1.0
+	if len(factors) > 1 {
+		actual := 0.0
+		for _, f := range factors[1:] {
+			for _, v := range f {
+				actual = math.Max(actual, math.Abs(v))
+			}
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		encode83(hash, quantised, 1)
+	} else {
+		encode83(hash, 0, 1)
+	}
+
+	dc := factors[0]
+	encode83(hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+
+	for _, f := range factors[1:] {
+		q := [3]int{}
+		for c, v := range f {
+			q[c] = int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		encode83(hash, q[0]*19*19+q[1]*19+q[2], 2)
+	}
+
+	return hash.String(), nil
+}
+
+// DecodeBlurHash renders a BlurHash at width x height, punch scales the
+// contrast and defaults to 1 when zero
+func DecodeBlurHash(hash string, width, height int, punch float64) (*image.NRGBA, error) {
+	if len(hash) < 6 {
+		return nil, errors.New("blurhash must be at least 6 characters")
+	}
+
+	if width <= 0 || height <= 0 || width*height > MAX_PIXELS {
+		return nil, fmt.Errorf("invalid blurhash size %dx%d", width, height)
+	}
+
+	if punch == 0 {
+		punch = 1
+	}
+
+	size, err := decode83(hash[:1])
+	if err != nil {
+		return nil, err
+	}
+	nx, ny := size%9+1, size/9+1
+	if len(hash) != 4+2*nx*ny {
+		return nil, fmt.Errorf("blurhash length %d is not valid for %dx%d components", len(hash), nx, ny)
+	}
+
+	quantised, err := decode83(hash[1:2])
+	if err != nil {
+		return nil, err
+	}
+	maximum := float64(quantised+1) / 166
+
+	colors := make([][3]float64, nx*ny)
+	for k := range colors {
+		if k == 0 {
+			v, err := decode83(hash[2:6])
+			if err != nil {
+				return nil, err
+			}
+			colors[k] = [3]float64{sRGBToLinear(uint8(v >> 16)), sRGBToLinear(uint8(v >> 8)), sRGBToLinear(uint8(v))}
+			continue
+		}
+
+		v, err := decode83(hash[4+k*2 : 6+k*2])
+		if err != nil {
+			return nil, err
+		}
+		q := [3]int{v / (19 * 19), (v / 19) % 19, v % 19}
+		for c := 0; c < 3; c++ {
+			colors[k][c] = signPow((float64(q[c])-9)/9, 2) * maximum * punch
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			var pixel [3]float64
+			for y := 0; y < ny; y++ {
+				cy := math.Cos(math.Pi * float64(py) * float64(y) / float64(height))
+				for x := 0; x < nx; x++ {
+					basis := math.Cos(math.Pi*float64(px)*float64(x)/float64(width)) * cy
+					for c := 0; c < 3; c++ {
+						pixel[c] += colors[y*nx+x][c] * basis
+					}
+				}
+			}
+
+			k := dst.PixOffset(px, py)
+			for c := 0; c < 3; c++ {
+				dst.Pix[k+c] = uint8(linearToSRGB(pixel[c]))
+			}
+			dst.Pix[k+3] = 255
+		}
+	}
+
+	return dst, nil
+}
+
+// encode83 appends value as length base 83 digits
+func encode83(b *strings.Builder, value, length int) {
+	for k := 1; k <= length; k++ {
+		digit := (value / int(math.Pow(83, float64(length-k)))) % 83
+		b.WriteByte(blurHashAlphabet[digit])
+	}
+}
+
+// decode83 ..
+func decode83(s string) (int, error) {
+	value := 0
+	for _, r := range s {
+		digit := strings.IndexRune(blurHashAlphabet, r)
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid blurhash character %q", r)
+		}
+		value = value*83 + digit
+	}
+	return value, nil
+}
+
+// sRGBToLinear ..
+func sRGBToLinear(v uint8) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB ..
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow ..
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,136 @@
+package himage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBlurHash(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.NRGBA{R: 200, G: 100, B: 50, A: 255}), image.Point{}, draw.Src)
+
+	hash, err := EncodeBlurHash(src, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 4+2*4*3 {
+		t.Error(errors.New("blurhash length is not valid"))
+	}
+
+	im, err := DecodeBlurHash(hash, 8, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := im.NRGBAAt(4, 4)
+	if c.R < 198 || c.R > 202 || c.G < 98 || c.G > 102 || c.B < 48 || c.B > 52 {
+		t.Error(errors.New("decoded blurhash color is not valid"))
+	}
+
+	if _, err := EncodeBlurHash(src, 0, 10); err == nil {
+		t.Error(errors.New("invalid components should fail"))
+	}
+}
+
+func TestDecodeBlurHash(t *testing.T) {
+	im, err := DecodeBlurHash("LEHV6nWB2yk8pyo0adR*.7kCMdnj", 32, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if im.Bounds().Dx() != 32 || im.Bounds().Dy() != 20 {
+		t.Error(errors.New("decoded size is not valid"))
+	}
+
+	if _, err := DecodeBlurHash("LEHV6nWB2yk8pyo0adR*.7kCMdn", 32, 20, 0); err == nil {
+		t.Error(errors.New("invalid length should fail"))
+	}
+
+	if _, err := DecodeBlurHash("LEHV6nWB2yk8pyo0adR*.7kCMd\"j", 32, 20, 0); err == nil {
+		t.Error(errors.New("invalid character should fail"))
+	}
+}
+
+func TestHimageBlurHashPlaceholder(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg"))
+	hash, err := hImage.BlurHash(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 28 {
+		t.Error(errors.New("blurhash is not valid"))
+	}
+
+	lqip, err := hImage.Placeholder(Placeholder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lqip.Width != 16 || lqip.Height != 11 || lqip.DataURI[:23] != "data:image/jpeg;base64," {
+		t.Error(errors.New("placeholder is not valid"))
+	}
+
+	if len(lqip.Hex()) != 7 || lqip.Color.A != 255 {
+		t.Error(errors.New("dominant color is not valid"))
+	}
+
+	lqip, err = hImage.Placeholder(Placeholder{Width: 8, Format: "png"})
+	if err != nil || lqip.DataURI[:22] != "data:image/png;base64," {
+		t.Error(errors.New("png placeholder is not valid"))
+	}
+}
+
+func TestPlaceholderBlur(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg"))
+
+	edges := func(option Placeholder) float64 {
+		lqip, err := hImage.Placeholder(option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(lqip.DataURI, "data:image/png;base64,"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gray := grayPixels(im, im.Bounds().Dx(), im.Bounds().Dy())
+		return laplacianVariance(gray, im.Bounds().Dx(), im.Bounds().Dy())
+	}
+
+	blurred := edges(Placeholder{Format: "png"})
+	sharp := edges(Placeholder{Format: "png", NoBlur: true})
+	if blurred >= sharp/2 {
+		t.Errorf("default placeholder is not blurred, edges %.1f and %.1f", blurred, sharp)
+	}
+}
+
+func TestPlaceholderValid(t *testing.T) {
+	if (Placeholder{}).Valid() != nil {
+		t.Error(errors.New("zero placeholder should use the defaults"))
+	}
+
+	err := (Placeholder{Width: 129}).Valid()
+	if err == nil || !strings.Contains(err.Error(), "0 means the default") {
+		t.Error(errors.New("placeholder width error is not valid"))
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.NRGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(0, 0, 3, 10), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	if c := dominantColor(src); c != (color.NRGBA{B: 255, A: 255}) {
+		t.Error(errors.New("dominant color is not valid"))
+	}
+}
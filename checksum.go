@@ -0,0 +1,53 @@
+package himage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checksumWriter tees w into a SHA-256 hash when enabled
+func checksumWriter(w io.Writer, enabled bool) (io.Writer, hash.Hash) {
+	h := sha256.New()
+	if !enabled {
+		return w, h
+	}
+	return io.MultiWriter(w, h), h
+}
+
+// checksumHex ..
+func checksumHex(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// outputChecksum reports whether the result written by Finish is hashed
+func (i *Himage) outputChecksum() bool {
+	return i.checksum&ChecksumOutput != 0 || i.naming == NamingContent
+}
+
+// moveContentAddressed renames a written file to ab/cd/<hash><ext> under
+// the destination, an existing file with the same hash is kept
+func (i *Himage) moveContentAddressed(written string) *Himage {
+	sum := i.Detail.OutputSHA256
+	output := filepath.Join(i.dst, sum[0:2], sum[2:4], sum+i.extension())
+
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		os.Remove(written)
+		i.Error = err
+		return i
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		os.Remove(written)
+	} else if err := os.Rename(written, output); err != nil {
+		os.Remove(written)
+		i.Error = err
+		return i
+	}
+	i.output = output
+
+	return i
+}
@@ -0,0 +1,70 @@
+package himage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageChecksum(t *testing.T) {
+	data, _ := ioutil.ReadFile(filepath.Join("test-files", "10x10.png"))
+	sum := sha256.Sum256(data)
+	expected := hex.EncodeToString(sum[:])
+
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	f, _ := os.Open(filepath.Join("test-files", "10x10.png"))
+	defer f.Close()
+	hImage := NewHimageWithFile(f).
+		SetDestination(dst).
+		SetChecksum(ChecksumSource | ChecksumOutput).
+		Move()
+	if _, err := hImage.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if hImage.Detail.SourceSHA256 != expected || hImage.Detail.OutputSHA256 != expected {
+		t.Error(errors.New("checksum is not valid"))
+	}
+
+	written, _ := ioutil.ReadFile(hImage.Output())
+	if len(written) != len(data) {
+		t.Error(errors.New("written file is not valid"))
+	}
+}
+
+func TestHimageNamingContent(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	outputs := make([]string, 0)
+	for k := 0; k < 2; k++ {
+		hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+			SetDestination(dst).
+			SetNaming(NamingContent).
+			Resize(Resize{Width: 100})
+		if _, err := hImage.Finish(); err != nil {
+			t.Fatal(err)
+		}
+
+		hash := hImage.Detail.OutputSHA256
+		if hImage.Output() != filepath.Join(dst, hash[:2], hash[2:4], hash+".jpg") {
+			t.Error(errors.New("content addressed output is not valid"))
+		}
+		outputs = append(outputs, hImage.Output())
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Error(errors.New("identical results should share a file"))
+	}
+
+	entries, _ := ioutil.ReadDir(dst)
+	if len(entries) != 1 {
+		t.Error(errors.New("temporary files should be removed"))
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/streetbyters/himage"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// info is the JSON output of the info command
+type info struct {
+	Path string
+	himage.Detail
+}
+
+// runInfo ..
+func runInfo(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("info", "file...", stderr)
+	asJSON := fs.Bool("json", false, "print one JSON object per file")
+	inspect := fs.Bool("inspect", false, "sample transparency and the color count")
+	analyze := fs.Bool("analyze", false, "add histograms, exposure and the blur score")
+	palette := fs.Int("palette", 0, "extract up to n palette colors")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	return eachFile(fs.Args(), stderr, func(file string) error {
+		i := himage.NewHimageWithPath(file)
+		defer i.Finish()
+
+		if *inspect {
+			i.Inspect()
+		}
+		if *analyze {
+			i.Analyze()
+		}
+		if *palette > 0 {
+			i.Palette(*palette)
+		}
+		if i.Error != nil {
+			return i.Error
+		}
+
+		if *asJSON {
+			return json.NewEncoder(stdout).Encode(info{Path: file, Detail: i.Detail})
+		}
+		return printDetail(stdout, file, i.Detail)
+	})
+}
+
+// printDetail prints the set detail fields as an aligned table
+func printDetail(w io.Writer, file string, d himage.Detail) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(key string, value interface{}) {
+		fmt.Fprintf(tw, "  %s\t%v\n", key, value)
+	}
+
+	fmt.Fprintln(tw, file)
+	row("mime", d.Mime)
+	row("size", d.Size)
+	row("resolution", fmt.Sprintf("%dx%d", d.Width, d.Height))
+	if d.Frames > 1 {
+		row("frames", d.Frames)
+		row("duration", d.Duration)
+		row("loop count", d.LoopCount)
+	}
+	if d.ViewBox != (himage.ViewBox{}) {
+		row("viewbox", fmt.Sprintf("%g %g %g %g", d.ViewBox.X, d.ViewBox.Y, d.ViewBox.Width, d.ViewBox.Height))
+	}
+	if d.Inspected {
+		row("alpha", d.Alpha)
+		row("colors", d.Colors)
+	}
+	if len(d.Palette) > 0 {
+		colors := make([]string, 0, len(d.Palette))
+		for _, c := range d.Palette {
+			colors = append(colors, fmt.Sprintf("%s %.1f%%", c.Hex(), c.Population*100))
+		}
+		row("palette", strings.Join(colors, ", "))
+		row("average color", himage.PaletteColor{Color: d.AverageColor}.Hex())
+		row("tone", d.Tone)
+	}
+	if a := d.Analysis; a != nil {
+		row("luminance", fmt.Sprintf("%.3f", a.Luminance))
+		row("contrast", fmt.Sprintf("%.1f", a.Contrast))
+		row("sharpness", fmt.Sprintf("%.1f", a.Sharpness))
+		row("blurry", a.Blurry)
+		row("flat", a.Flat)
+		row("underexposed", a.Underexposed)
+		row("overexposed", a.Overexposed)
+	}
+
+	return tw.Flush()
+}
+
+// runResize ..
+func runResize(args []string, stdout, stderr io.Writer) error {
+	step := himage.Step{Op: "resize"}
+	fs := newFlagSet("resize", "file...", stderr)
+	stepFlags(fs, &step, "anchor", "ratio", "width", "height", "width_oriented", "height_oriented", "maximize", "minimize", "sigma", "amount", "threshold", "no_sharpen")
+	return runStep(fs, args, &step, stdout, stderr)
+}
+
+// runCrop ..
+func runCrop(args []string, stdout, stderr io.Writer) error {
+	step := himage.Step{Op: "crop"}
+	fs := newFlagSet("crop", "file...", stderr)
+	stepFlags(fs, &step, "anchor", "width", "height")
+	return runStep(fs, args, &step, stdout, stderr)
+}
+
+// runConvert ..
+func runConvert(args []string, stdout, stderr io.Writer) error {
+	step := himage.Step{Op: "convert"}
+	fs := newFlagSet("convert", "file...", stderr)
+	stepFlags(fs, &step, "format", "quality")
+	return runStep(fs, args, &step, stdout, stderr)
+}
+
+// runOptimize ..
+func runOptimize(args []string, stdout, stderr io.Writer) error {
+	step := himage.Step{Op: "optimize"}
+	fs := newFlagSet("optimize", "file...", stderr)
+	stepFlags(fs, &step, "max_size", "min_quality", "min_ssim")
+	return runStep(fs, args, &step, stdout, stderr)
+}
+
+// runStep runs the single step whose fields were registered on fs
+func runStep(fs *flag.FlagSet, args []string, step *himage.Step, stdout, stderr io.Writer) error {
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	p, err := stepPipeline(*step)
+	if err != nil {
+		return err
+	}
+
+	return transform(p, fs.Args(), out, &himage.Batch{Workers: 1}, stdout, stderr)
+}
+
+// runHash ..
+func runHash(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("hash", "file...", stderr)
+	name := fs.String("kind", "phash", "hash kind, ahash, dhash or phash")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	kind, err := parseHashKind(*name)
+	if err != nil {
+		return err
+	}
+
+	return eachFile(fs.Args(), stderr, func(file string) error {
+		i := himage.NewHimageWithPath(file)
+		defer i.Finish()
+
+		hash, err := i.Hash(kind)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(stdout, "%s  %s\n", hash, file)
+		return err
+	})
+}
+
+// parseHashKind ..
+func parseHashKind(name string) (himage.HashKind, error) {
+	for kind := himage.AHash; kind <= himage.PHash; kind++ {
+		if kind.String() == strings.ToLower(name) {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid hash kind %q", name)
+}
+
+// runBatch ..
+func runBatch(args []string, stdout, stderr io.Writer) error {
+	var pf pipelineFlags
+	var out outputFlags
+	fs := newFlagSet("batch", "glob...", stderr)
+	pf.register(fs)
+	out.register(fs)
+	workers := fs.Int("workers", 0, "concurrent workers, defaults to the CPU count")
+	memory := fs.Int64("memory", 0, "estimated decoded bytes in flight, zero means unlimited")
+	failFast := fs.Bool("fail_fast", false, "stop scheduling after the first failure")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "no input globs")
+		fs.Usage()
+		return errUsage
+	}
+
+	p, err := pf.pipeline()
+	if err != nil {
+		return err
+	}
+
+	files, err := glob(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	b := &himage.Batch{Workers: *workers, MemoryBudget: *memory, FailFast: *failFast}
+	return transform(p, files, out, b, stdout, stderr)
+}
+
+// glob expands the patterns into unique regular files in match order
+func glob(patterns []string) ([]string, error) {
+	files := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+
+		for _, match := range matches {
+			if stat, err := os.Stat(match); err != nil || stat.IsDir() || seen[match] {
+				continue
+			}
+			seen[match] = true
+			files = append(files, match)
+		}
+	}
+
+	return files, nil
+}
+
+// transform runs p over the files on b and prints the written paths, a
+// destination of - streams the single result to stdout
+func transform(p *himage.Pipeline, files []string, out outputFlags, b *himage.Batch, stdout, stderr io.Writer) error {
+	if err := out.valid(files); err != nil {
+		return err
+	}
+
+	if out.dst == "-" {
+		i := p.Apply(himage.NewHimageWithPath(files[0]))
+		defer i.Finish()
+
+		_, err := i.WriteTo(stdout)
+		return err
+	}
+
+	b.Destination = out.dst
+	b.Pipeline = p.Apply
+	results, err := b.Run(files)
+
+	failed := 0
+	written := make(map[string]bool)
+	for _, r := range results {
+		if r.Error == nil {
+			r.Output, r.Error = rename(r, out.name, written)
+		}
+		if r.Error != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: %s\n", r.Source, r.Error)
+			continue
+		}
+		fmt.Fprintln(stdout, r.Output)
+	}
+
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d item(s) failed", failed, len(results))
+	}
+	return err
+}
+
+// rename moves a batch output to name, the source name by default.
+// Batch writes random names so concurrent items never share a file.
+func rename(r himage.BatchResult, name string, written map[string]bool) (string, error) {
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(r.Source), filepath.Ext(r.Source))
+	}
+	output := filepath.Join(filepath.Dir(r.Output), name+filepath.Ext(r.Output))
+
+	source, _ := filepath.Abs(r.Source)
+	target, _ := filepath.Abs(output)
+	if target == source || written[target] {
+		os.Remove(r.Output)
+		if target == source {
+			return "", errors.New("output would overwrite the source, set -name or another -o")
+		}
+		return "", fmt.Errorf("output %s is written by another source", output)
+	}
+	written[target] = true
+
+	return output, os.Rename(r.Output, output)
+}
+
+// eachFile runs fn for every file and reports the failures on stderr
+func eachFile(files []string, stderr io.Writer, fn func(file string) error) error {
+	failed := 0
+	for _, file := range files {
+		if err := fn(file); err != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: %s\n", file, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", failed, len(files))
+	}
+	return nil
+}
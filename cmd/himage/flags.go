@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/streetbyters/himage"
+	"github.com/streetbyters/himage/himagehttp"
+)
+
+// stepFlags registers the given pipeline spec fields of step as flags of
+// the same name
+func stepFlags(fs *flag.FlagSet, step *himage.Step, fields ...string) {
+	for _, field := range fields {
+		switch field {
+		case "anchor":
+			fs.Var((*anchorValue)(&step.Anchor), field, "anchor point like top or bottom-right, resize fills the box and crops at it unless center")
+		case "ratio":
+			fs.IntVar(&step.Ratio, field, 0, "shrink the source sides by 1/ratio, or grow them with -maximize")
+		case "width":
+			fs.IntVar(&step.Width, field, 0, "width in pixels")
+		case "height":
+			fs.IntVar(&step.Height, field, 0, "height in pixels")
+		case "width_oriented":
+			fs.BoolVar(&step.WidthOriented, field, false, "with -ratio, scale the width only and keep the aspect ratio")
+		case "height_oriented":
+			fs.BoolVar(&step.HeightOriented, field, false, "with -ratio, scale the height only and keep the aspect ratio")
+		case "maximize":
+			fs.BoolVar(&step.Maximize, field, false, "with -ratio, grow the source instead of shrinking it")
+		case "minimize":
+			fs.BoolVar(&step.Minimize, field, false, "with -ratio, shrink the source, the default")
+		case "sigma":
+			fs.Float64Var(&step.Sigma, field, 0, "sharpen sigma applied after downscaling")
+		case "no_sharpen":
+			fs.BoolVar(&step.NoSharpen, field, false, "do not sharpen after downscaling")
+		case "amount":
+			fs.Float64Var(&step.Amount, field, 0, "sharpen amount")
+		case "threshold":
+			fs.Float64Var(&step.Threshold, field, 0, "sharpen threshold in 0-255")
+		case "format":
+			fs.StringVar(&step.Format, field, "", "output format like jpg, png or gif")
+		case "quality":
+			fs.IntVar(&step.Quality, field, 0, "JPEG quality")
+		case "max_size":
+			fs.Int64Var(&step.MaxSize, field, 0, "maximum encoded size in bytes")
+		case "min_quality":
+			fs.IntVar(&step.MinQuality, field, 0, "lowest JPEG quality allowed")
+		case "min_ssim":
+			fs.Float64Var(&step.MinSSIM, field, 0, "lowest structural similarity allowed")
+		default:
+			panic(fmt.Sprintf("unknown step flag %q", field))
+		}
+	}
+}
+
+// anchorValue is a flag.Value for himage.Anchor
+type anchorValue himage.Anchor
+
+// String ..
+func (a *anchorValue) String() string {
+	return himage.Anchor(*a).String()
+}
+
+// Set ..
+func (a *anchorValue) Set(s string) error {
+	return (*himage.Anchor)(a).UnmarshalText([]byte(s))
+}
+
+// stepPipeline wraps a single step into a validated pipeline
+func stepPipeline(step himage.Step) (*himage.Pipeline, error) {
+	p := &himage.Pipeline{Steps: []himage.Step{step}}
+	if err := p.Valid(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// pipelineFlags selects a pipeline from a spec file or an ops string
+type pipelineFlags struct {
+	file string
+	ops  string
+}
+
+// register ..
+func (f *pipelineFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.file, "pipeline", "", "pipeline spec file, .json or .yaml")
+	fs.StringVar(&f.ops, "ops", "", "pipeline as URL ops like resize:w=300,convert:f=png")
+}
+
+// pipeline loads the single selected pipeline
+func (f *pipelineFlags) pipeline() (*himage.Pipeline, error) {
+	if (f.file == "") == (f.ops == "") {
+		return nil, errors.New("exactly one of -pipeline or -ops is required")
+	}
+
+	if f.file != "" {
+		return himage.LoadPipeline(f.file)
+	}
+	return himagehttp.ParseOps(f.ops)
+}
+
+// outputFlags are the destination flags of the transform commands
+type outputFlags struct {
+	dst  string
+	name string
+}
+
+// register ..
+func (f *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dst, "o", "", "destination directory, - writes a single image to stdout")
+	fs.StringVar(&f.name, "name", "", "output name without extension for a single image, defaults to the source name")
+}
+
+// valid ..
+func (f *outputFlags) valid(files []string) error {
+	if f.dst == "" {
+		return errors.New("missing -o destination")
+	}
+
+	if (f.dst == "-" || f.name != "") && len(files) > 1 {
+		return errors.New("-o - and -name need a single input file")
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/streetbyters/himage"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStepFlags(t *testing.T) {
+	step := himage.Step{Op: "resize"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	stepFlags(fs, &step, "anchor", "width", "height_oriented", "sigma")
+
+	if err := fs.Parse([]string{"-anchor", "bottom_right", "-width", "300", "-height_oriented", "-sigma", "0.5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if step.Anchor != himage.BottomRight || step.Width != 300 || !step.HeightOriented || step.Sigma != 0.5 {
+		t.Error(errors.New("step flags are not valid"))
+	}
+
+	if fs.Lookup("anchor").Value.String() != "bottom-right" {
+		t.Error(errors.New("anchor flag string is not valid"))
+	}
+
+	if err := fs.Parse([]string{"-ratio", "50"}); err == nil {
+		t.Error(errors.New("unregistered step flag is not valid"))
+	}
+}
+
+func TestPipelineFlags(t *testing.T) {
+	f := pipelineFlags{ops: "resize:w=10,convert:f=png"}
+	p, err := f.pipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Steps) != 2 || p.Steps[0].Width != 10 {
+		t.Error(errors.New("ops pipeline is not valid"))
+	}
+
+	if _, err := (&pipelineFlags{ops: "resize:w=10", file: "spec.json"}).pipeline(); err == nil {
+		t.Error(errors.New("both pipeline sources are not valid"))
+	}
+
+	if _, err := (&pipelineFlags{}).pipeline(); err == nil {
+		t.Error(errors.New("missing pipeline source is not valid"))
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	if err := (&outputFlags{}).valid([]string{"a.jpg"}); err == nil {
+		t.Error(errors.New("missing destination is not valid"))
+	}
+
+	if err := (&outputFlags{dst: "out", name: "x"}).valid([]string{"a.jpg", "b.jpg"}); err == nil {
+		t.Error(errors.New("name with many files is not valid"))
+	}
+
+	if err := (&outputFlags{dst: "-"}).valid([]string{"a.jpg"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,109 @@
+// Command himage runs himage transforms from the command line. Flags are
+// named after the pipeline spec fields, so a step like
+//
+//	{"op": "resize", "width": 300, "anchor": "top"}
+//
+// is reproduced with
+//
+//	himage resize -width 300 -anchor top -o out/ photo.jpg
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errUsage is returned after the flag set printed its own error
+var errUsage = errors.New("usage")
+
+// command ..
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+// commands ..
+var commands = []command{
+	{"info", "print the image details as text or JSON", runInfo},
+	{"resize", "resize images", runResize},
+	{"crop", "crop images", runCrop},
+	{"convert", "convert images to another format", runConvert},
+	{"optimize", "fit images under a size", runOptimize},
+	{"hash", "print perceptual hashes", runHash},
+	{"batch", "run a pipeline over globs on a worker pool", runBatch},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches the subcommand and returns the exit code, 2 for usage errors
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		err := c.run(args[1:], stdout, stderr)
+		if err == errUsage {
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "himage %s: %s\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "himage: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+// usage ..
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: himage <command> [flags] file...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run himage <command> -h for the flags of a command, flags are")
+	fmt.Fprintln(w, "snake_case like the pipeline spec fields, e.g. -max_size or -fail_fast")
+}
+
+// newFlagSet ..
+func newFlagSet(name string, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: himage %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags and requires at least one argument
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(fs.Output(), "no input files")
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testFile ..
+func testFile(name string) string {
+	return filepath.Join("..", "..", "test-files", name)
+}
+
+// runTest runs the command line and returns the exit code and outputs
+func runTest(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// tempDir ..
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "himage-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// decodeConfig ..
+func decodeConfig(t *testing.T, path string) (image.Config, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	c, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, format
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, stderr := runTest(); code != 2 || !strings.Contains(stderr, "batch") || !strings.Contains(stderr, "snake_case") {
+		t.Error(errors.New("usage is not valid"))
+	}
+
+	if code, _, stderr := runTest("unknown"); code != 2 || !strings.Contains(stderr, `unknown command "unknown"`) {
+		t.Error(errors.New("unknown command is not valid"))
+	}
+
+	if code, _, _ := runTest("resize", "-unknown", "1"); code != 2 {
+		t.Error(errors.New("unknown flag exit code is not valid"))
+	}
+
+	if code, _, stderr := runTest("info"); code != 2 || !strings.Contains(stderr, "no input files") {
+		t.Error(errors.New("missing input files are not valid"))
+	}
+}
+
+func TestInfo(t *testing.T) {
+	code, stdout, stderr := runTest("info", "-palette", "3", testFile("100x80.gif"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	for _, s := range []string{"image/gif", "100x80", "frames", "palette", "tone"} {
+		if !strings.Contains(stdout, s) {
+			t.Error(errors.New("info text " + s + " is not valid"))
+		}
+	}
+
+	code, stdout, stderr = runTest("info", "-json", "-analyze", testFile("640x426.jpeg"), testFile("10x10.png"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	for _, want := range []int{640, 10} {
+		var result info
+		if err := decoder.Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Width != want || result.Analysis == nil || result.Path == "" {
+			t.Error(errors.New("info json is not valid"))
+		}
+	}
+}
+
+func TestInfoMissing(t *testing.T) {
+	code, stdout, stderr := runTest("info", testFile("10x10.png"), testFile("missing.png"))
+	if code != 1 || !strings.Contains(stdout, "10x10") {
+		t.Error(errors.New("info exit code is not valid"))
+	}
+
+	if !strings.Contains(stderr, "missing.png") || !strings.Contains(stderr, "1 of 2 file(s) failed") {
+		t.Error(errors.New("info errors are not valid"))
+	}
+}
+
+func TestResize(t *testing.T) {
+	dir := tempDir(t)
+	code, stdout, stderr := runTest("resize", "-width", "100", "-height", "50", "-anchor", "top", "-o", dir, testFile("640x426.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	output := strings.TrimSpace(stdout)
+	if output != filepath.Join(dir, "640x426.jpg") {
+		t.Fatal(errors.New("resize output is not valid"))
+	}
+
+	if c, _ := decodeConfig(t, output); c.Width != 100 || c.Height != 50 {
+		t.Error(errors.New("resize resolution is not valid"))
+	}
+}
+
+func TestResizeInvalid(t *testing.T) {
+	code, _, stderr := runTest("resize", "-width", "100", "-ratio", "50", "-o", tempDir(t), testFile("640x426.jpeg"))
+	if code != 1 || !strings.Contains(stderr, "steps[0].ratio") {
+		t.Error(errors.New("resize validation is not valid"))
+	}
+
+	if code, _, _ := runTest("resize", "-anchor", "middle", "-o", tempDir(t), testFile("640x426.jpeg")); code != 2 {
+		t.Error(errors.New("resize anchor validation is not valid"))
+	}
+
+	if code, _, stderr := runTest("resize", "-width", "100", testFile("640x426.jpeg")); code != 1 || !strings.Contains(stderr, "missing -o") {
+		t.Error(errors.New("resize destination validation is not valid"))
+	}
+}
+
+func TestCrop(t *testing.T) {
+	dir := tempDir(t)
+	code, stdout, stderr := runTest("crop", "-width", "20", "-height", "30", "-name", "cropped", "-o", dir, testFile("850x566.png"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	if c, _ := decodeConfig(t, filepath.Join(dir, "cropped.png")); c.Width != 20 || c.Height != 30 || strings.TrimSpace(stdout) != filepath.Join(dir, "cropped.png") {
+		t.Error(errors.New("crop output is not valid"))
+	}
+}
+
+func TestConvertStdout(t *testing.T) {
+	code, stdout, stderr := runTest("convert", "-format", "png", "-o", "-", testFile("640x426.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	c, format, err := image.DecodeConfig(strings.NewReader(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || c.Width != 640 {
+		t.Error(errors.New("convert stdout is not valid"))
+	}
+
+	if code, _, _ := runTest("convert", "-format", "png", "-o", "-", testFile("640x426.jpeg"), testFile("10x10.png")); code != 1 {
+		t.Error(errors.New("convert stdout with many files is not valid"))
+	}
+}
+
+func TestConvertSourceDirectory(t *testing.T) {
+	dir := tempDir(t)
+	data, err := ioutil.ReadFile(testFile("10x10.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "10x10.png")
+	if err := ioutil.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runTest("convert", "-format", "png", "-o", dir, source)
+	if code != 1 || !strings.Contains(stderr, "overwrite the source") {
+		t.Error(errors.New("convert into the source directory is not valid"))
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Error(errors.New("convert leftover output is not valid"))
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	dir := tempDir(t)
+	code, stdout, stderr := runTest("optimize", "-max_size", "60000", "-min_quality", "10", "-o", dir, testFile("640x426.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	stat, err := os.Stat(strings.TrimSpace(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() > 60000 {
+		t.Error(errors.New("optimize size is not valid"))
+	}
+}
+
+func TestHash(t *testing.T) {
+	code, stdout, stderr := runTest("hash", "-kind", "dhash", testFile("640x426.jpeg"), testFile("1280x853.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || len(strings.Fields(lines[0])[0]) != 16 {
+		t.Error(errors.New("hash output is not valid"))
+	}
+
+	if code, _, _ := runTest("hash", "-kind", "md5", testFile("640x426.jpeg")); code != 1 {
+		t.Error(errors.New("hash kind validation is not valid"))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := tempDir(t)
+	code, stdout, stderr := runTest("batch", "-ops", "resize:w=20:h=10,convert:f=png", "-workers", "2", "-o", dir, testFile("*.png"), testFile("640x*.jpeg"), testFile("10x10.png"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	outputs := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(outputs) != 5 || outputs[4] != filepath.Join(dir, "640x426.png") {
+		t.Fatal(errors.New("batch outputs are not valid"))
+	}
+
+	for _, output := range outputs {
+		if c, format := decodeConfig(t, output); c.Width != 20 || c.Height != 10 || format != "png" {
+			t.Error(errors.New("batch output " + output + " is not valid"))
+		}
+	}
+}
+
+func TestBatchPipelineFile(t *testing.T) {
+	dir := tempDir(t)
+	spec := filepath.Join(dir, "spec.yaml")
+	if err := ioutil.WriteFile(spec, []byte("steps:\n  - op: crop\n    width: 8\n    height: 8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runTest("batch", "-pipeline", spec, "-o", filepath.Join(dir, "out"), testFile("1*.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	if outputs := strings.Split(strings.TrimSpace(stdout), "\n"); len(outputs) != 2 {
+		t.Error(errors.New("batch pipeline file outputs are not valid"))
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	dir := tempDir(t)
+	if code, _, stderr := runTest("batch", "-o", dir, testFile("*.png")); code != 1 || !strings.Contains(stderr, "-pipeline or -ops") {
+		t.Error(errors.New("batch pipeline validation is not valid"))
+	}
+
+	if code, _, stderr := runTest("batch", "-ops", "crop:w=4:h=4", "-o", dir, testFile("*.xyz")); code != 1 || !strings.Contains(stderr, "no files match") {
+		t.Error(errors.New("batch glob validation is not valid"))
+	}
+
+	bad := filepath.Join(dir, "bad.png")
+	if err := ioutil.WriteFile(bad, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runTest("batch", "-ops", "crop:w=4:h=4", "-o", filepath.Join(dir, "out"), bad, testFile("100x80.gif"))
+	if code != 1 || !strings.Contains(stdout, "100x80.gif") || !strings.Contains(stderr, "bad.png") {
+		t.Error(errors.New("batch partial failure is not valid"))
+	}
+
+	code, _, stderr = runTest("batch", "-ops", "crop:w=4:h=4", "-workers", "1", "-fail_fast", "-o", filepath.Join(dir, "fast"), bad, testFile("100x80.gif"))
+	if code != 1 || !strings.Contains(stderr, "bad.png") {
+		t.Error(errors.New("batch fail fast is not valid"))
+	}
+}
+
+func TestResizeRatio(t *testing.T) {
+	dir := tempDir(t)
+	code, stdout, stderr := runTest("resize", "-ratio", "4", "-maximize", "-o", dir, testFile("640x426.jpeg"))
+	if code != 0 {
+		t.Fatal(errors.New(stderr))
+	}
+
+	if c, _ := decodeConfig(t, strings.TrimSpace(stdout)); c.Width != 800 || c.Height != 532 {
+		t.Error(errors.New("resize ratio is not valid"))
+	}
+}
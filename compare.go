@@ -0,0 +1,194 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"math"
+)
+
+// Metrics are full reference quality metrics of two images
+type Metrics struct {
+	// MSE is the mean squared error of the RGB channels
+	MSE float64
+	// PSNR is the peak signal to noise ratio in dB, +Inf for identical images
+	PSNR float64
+	// SSIM is the mean structural similarity of the luma, 1 for identical images
+	SSIM float64
+}
+
+// Compare computes the metrics of two images of the same size
+func Compare(a, b *Himage) (Metrics, error) {
+	x, y, err := compareImages(a, b, false)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	return CompareImages(x, y)
+}
+
+// CompareResized computes the metrics after resizing b to the size of a
+func CompareResized(a, b *Himage) (Metrics, error) {
+	x, y, err := compareImages(a, b, true)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	return CompareImages(x, y)
+}
+
+// CompareImages computes the metrics of two images of the same size
+func CompareImages(a, b image.Image) (Metrics, error) {
+	x, y := imaging.Clone(a), imaging.Clone(b)
+	if x.Bounds().Size() != y.Bounds().Size() {
+		return Metrics{}, fmt.Errorf("image sizes %v and %v differ", x.Bounds().Size(), y.Bounds().Size())
+	}
+
+	if x.Bounds().Empty() {
+		return Metrics{}, errors.New("images are empty")
+	}
+
+	sum := 0.0
+	for k := 0; k < len(x.Pix); k += 4 {
+		for c := k; c < k+3; c++ {
+			d := float64(x.Pix[c]) - float64(y.Pix[c])
+			sum += d * d
+		}
+	}
+
+	m := Metrics{MSE: sum / float64(len(x.Pix)/4*3)}
+	m.PSNR = math.Inf(1)
+	if m.MSE > 0 {
+		m.PSNR = 10 * math.Log10(255*255/m.MSE)
+	}
+	m.SSIM = ssim(x, y)
+
+	return m, nil
+}
+
+// DiffImage returns a faded grayscale copy of a with the pixels whose
+// channels differ from b by more than threshold painted red, and the
+// count of those pixels
+func DiffImage(a, b *Himage, threshold int) (*image.NRGBA, int, error) {
+	x, y, err := compareImages(a, b, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return DiffImages(x, y, threshold)
+}
+
+// DiffImages is DiffImage for decoded images
+func DiffImages(a, b image.Image, threshold int) (*image.NRGBA, int, error) {
+	dst := imaging.Clone(a)
+	other := imaging.Clone(b)
+	if dst.Bounds().Size() != other.Bounds().Size() {
+		return nil, 0, fmt.Errorf("image sizes %v and %v differ", dst.Bounds().Size(), other.Bounds().Size())
+	}
+
+	count := 0
+	for k := 0; k < len(dst.Pix); k += 4 {
+		differs := false
+		for c := k; c < k+4; c++ {
+			if int(math.Abs(float64(dst.Pix[c])-float64(other.Pix[c]))) > threshold {
+				differs = true
+			}
+		}
+
+		if differs {
+			count++
+			dst.Pix[k], dst.Pix[k+1], dst.Pix[k+2], dst.Pix[k+3] = 255, 0, 0, 255
+			continue
+		}
+
+		gray := uint8((0.299*float64(dst.Pix[k])+0.587*float64(dst.Pix[k+1])+0.114*float64(dst.Pix[k+2]))/4 + 191)
+		dst.Pix[k], dst.Pix[k+1], dst.Pix[k+2], dst.Pix[k+3] = gray, gray, gray, 255
+	}
+
+	return dst, count, nil
+}
+
+// compareImages decodes both images, b is resized to a when resize is set
+func compareImages(a, b *Himage, resize bool) (image.Image, image.Image, error) {
+	if a == nil || b == nil {
+		return nil, nil, errors.New("compared image is nil")
+	}
+
+	for _, i := range []*Himage{a, b} {
+		if i.Error != nil {
+			return nil, nil, i.Error
+		}
+	}
+
+	x, err := a.image()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	y, err := b.image()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resize && x.Bounds().Size() != y.Bounds().Size() {
+		y = imaging.Resize(y, x.Bounds().Dx(), x.Bounds().Dy(), imaging.Lanczos)
+	}
+
+	return x, y, nil
+}
+
+// ssim averages the structural similarity of 8x8 luma windows with a
+// stride of 4, smaller images are compared as a single window
+func ssim(x, y *image.NRGBA) float64 {
+	const window, stride = 8, 4
+	c1, c2 := math.Pow(0.01*255, 2), math.Pow(0.03*255, 2)
+
+	w, h := x.Bounds().Dx(), x.Bounds().Dy()
+	lx, ly := lumaPlane(x), lumaPlane(y)
+
+	ww, wh := window, window
+	if w < ww {
+		ww = w
+	}
+	if h < wh {
+		wh = h
+	}
+
+	total, n := 0.0, 0
+	for top := 0; top+wh <= h; top += stride {
+		for left := 0; left+ww <= w; left += stride {
+			var sx, sy, sxx, syy, sxy float64
+			for j := top; j < top+wh; j++ {
+				for i := left; i < left+ww; i++ {
+					a, b := lx[j*w+i], ly[j*w+i]
+					sx += a
+					sy += b
+					sxx += a * a
+					syy += b * b
+					sxy += a * b
+				}
+			}
+
+			count := float64(ww * wh)
+			mx, my := sx/count, sy/count
+			vx := sxx/count - mx*mx
+			vy := syy/count - my*my
+			cov := sxy/count - mx*my
+
+			total += (2*mx*my + c1) * (2*cov + c2) / ((mx*mx + my*my + c1) * (vx + vy + c2))
+			n++
+		}
+	}
+
+	return total / float64(n)
+}
+
+// lumaPlane ..
+func lumaPlane(im *image.NRGBA) []float64 {
+	luma := make([]float64, len(im.Pix)/4)
+	for k := range luma {
+		luma[k] = 0.299*float64(im.Pix[k*4]) + 0.587*float64(im.Pix[k*4+1]) + 0.114*float64(im.Pix[k*4+2])
+	}
+	return luma
+}
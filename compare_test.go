@@ -0,0 +1,94 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	a := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg"))
+	m, err := Compare(a, NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MSE != 0 || !math.IsInf(m.PSNR, 1) || m.SSIM < 0.9999 {
+		t.Error(errors.New("identical metrics are not valid"))
+	}
+
+	b := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).Blur(2)
+	defer b.Finish()
+	m, err = Compare(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MSE <= 0 || m.PSNR < 15 || m.PSNR > 50 || m.SSIM >= 0.99 || m.SSIM <= 0 {
+		t.Error(errors.New("blurred metrics are not valid"))
+	}
+
+	c := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).Resize(Resize{Width: 320})
+	defer c.Finish()
+	if _, err := Compare(a, c); err == nil {
+		t.Error(errors.New("different sizes should fail"))
+	}
+
+	m, err = CompareResized(a, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SSIM < 0.7 {
+		t.Error(errors.New("resized metrics are not valid"))
+	}
+}
+
+func TestDiffImage(t *testing.T) {
+	a := NewHimageWithPath(filepath.Join("test-files", "850x566.png"))
+	white := writeTestImage(t, func(im *image.NRGBA) {
+		for k := range im.Pix {
+			im.Pix[k] = 255
+		}
+	})
+	b := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).
+		Overlay(NewHimageWithPath(white), Overlay{Anchor: TopLeft})
+	if b.Error != nil {
+		t.Fatal(b.Error)
+	}
+	defer b.Finish()
+
+	diff, count, err := DiffImage(a, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count == 0 || count > 40*40 {
+		t.Error(errors.New("diff count is not valid"))
+	}
+
+	if c := diff.NRGBAAt(800, 500); c.R != c.G {
+		t.Error(errors.New("unchanged pixels should be gray"))
+	}
+}
+
+func TestHimageOptimizeMinSSIM(t *testing.T) {
+	original := NewHimageWithPath(filepath.Join("test-files", "1280x853.jpeg"))
+	hImage := NewHimageWithPath(filepath.Join("test-files", "1280x853.jpeg")).
+		Optimize(Optimize{MinSSIM: 0.95})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	m, err := Compare(original, hImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SSIM < 0.95 {
+		t.Error(errors.New("quality floor is not valid"))
+	}
+
+	if (Optimize{MinSSIM: 2}).Valid() == nil {
+		t.Error(errors.New("invalid ssim should fail"))
+	}
+}
@@ -3,4 +3,18 @@ package himage
 const (
 	// CHUNK_SIZE default file operation chunk size
 	CHUNK_SIZE int = 32 * 1024
+	// INSPECT_SIZE longest side of the sample used by Inspect
+	INSPECT_SIZE int = 256
+	// INSPECT_COLORS distinct color limit counted by Inspect
+	INSPECT_COLORS int = 1024
+	// SVG_MAX_SIZE maximum SVG document size in bytes
+	SVG_MAX_SIZE int64 = 8 * 1024 * 1024
+	// MAX_PIXELS maximum pixel count of a rendered image
+	MAX_PIXELS int = 100 * 1000 * 1000
+	// ANALYZE_SIZE longest side of the sample used by Analyze
+	ANALYZE_SIZE int = 512
+	// BLUR_THRESHOLD Laplacian variance below which Analyze reports blur
+	BLUR_THRESHOLD float64 = 100
+	// FLAT_THRESHOLD luminance standard deviation below which Analyze reports a flat image
+	FLAT_THRESHOLD float64 = 4
 )
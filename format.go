@@ -0,0 +1,229 @@
+package himage
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"strconv"
+	"strings"
+)
+
+// UnsupportedError is returned for images that cannot be decoded
+type UnsupportedError struct {
+	Mime   string
+	Reason string
+}
+
+// Error ..
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("cannot decode %s: %s", e.Mime, e.Reason)
+}
+
+// decoders mime types of the registered decoders by format name
+var decoders = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"tiff": "image/tiff",
+	"bmp":  "image/bmp",
+}
+
+// inputs decode only mime types and their file extensions, operations on
+// them are saved as PNG unless converted, SVG is rasterized first
+var inputs = map[string]string{
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// formats supported output mime types and their file extensions
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/tiff": ".tiff",
+	"image/bmp":  ".bmp",
+}
+
+// alphaFormats output mime types that can store transparency
+var alphaFormats = map[string]bool{
+	"image/png":  true,
+	"image/gif":  true,
+	"image/tiff": true,
+}
+
+// formatAliases short format names accepted in place of a mime type
+var formatAliases = map[string]string{
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"bmp":  "image/bmp",
+}
+
+// FormatMime resolves a format name (png, jpg ..) or mime type to a supported output mime type
+func FormatMime(format string) (string, error) {
+	f := strings.ToLower(strings.TrimSpace(format))
+	if f == "image/jpg" {
+		f = "image/jpeg"
+	}
+
+	if m, ok := formatAliases[f]; ok {
+		return m, nil
+	}
+
+	if _, ok := formats[f]; ok {
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unsupported output format %q", format)
+}
+
+// Inspect fills Detail.Alpha and Detail.Colors from a downsampled copy
+func (i *Himage) Inspect() *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	src, err := i.image()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	sample := src
+	if b := src.Bounds(); b.Dx() > INSPECT_SIZE || b.Dy() > INSPECT_SIZE {
+		sample = imaging.Fit(src, INSPECT_SIZE, INSPECT_SIZE, imaging.NearestNeighbor)
+	}
+	im := imaging.Clone(sample)
+
+	colors := make(map[uint32]bool)
+	alpha := false
+	for p := 0; p+3 < len(im.Pix); p += 4 {
+		if im.Pix[p+3] != 0xff {
+			alpha = true
+		}
+		if len(colors) < INSPECT_COLORS {
+			colors[uint32(im.Pix[p])<<24|uint32(im.Pix[p+1])<<16|uint32(im.Pix[p+2])<<8|uint32(im.Pix[p+3])] = true
+		}
+	}
+
+	i.Detail.Inspected = true
+	i.Detail.Alpha = alpha
+	i.Detail.Colors = len(colors)
+
+	return i
+}
+
+// ChooseFormat picks the output mime type for the image characteristics
+// and an HTTP Accept header. Flat graphics prefer PNG, tiny opaque
+// palettes GIF and photos JPEG; alpha prefers PNG. Without
+// Inspect the source format is preferred when it can be encoded.
+// Animations get GIF whenever it is accepted, the only output that
+// keeps their frames.
+func ChooseFormat(detail Detail, accept string) string {
+	candidates := formatCandidates(detail)
+	ranges := parseAccept(accept)
+
+	if detail.Frames > 1 && acceptQuality(ranges, "image/gif") > 0 {
+		return "image/gif"
+	}
+
+	best, bestQ := "", 0.0
+	for _, mime := range candidates {
+		if q := acceptQuality(ranges, mime); q > bestQ {
+			best, bestQ = mime, q
+		}
+	}
+
+	if best == "" {
+		return candidates[0]
+	}
+	return best
+}
+
+// formatCandidates returns output formats in preference order
+func formatCandidates(detail Detail) []string {
+	if detail.Frames > 1 {
+		return []string{"image/gif", "image/png", "image/jpeg"}
+	}
+
+	if !detail.Inspected {
+		if mime, err := FormatMime(detail.Mime); err == nil && mime != "image/tiff" && mime != "image/bmp" {
+			return []string{mime, "image/png", "image/jpeg"}
+		}
+		return []string{"image/png", "image/jpeg"}
+	}
+
+	switch {
+	case detail.Alpha:
+		if detail.Colors <= 256 {
+			return []string{"image/png", "image/gif", "image/jpeg"}
+		}
+		return []string{"image/png", "image/jpeg"}
+	case detail.Colors <= 16:
+		return []string{"image/gif", "image/png", "image/jpeg"}
+	case detail.Colors <= 256:
+		return []string{"image/png", "image/gif", "image/jpeg"}
+	}
+
+	return []string{"image/jpeg", "image/png"}
+}
+
+// acceptRange ..
+type acceptRange struct {
+	mime string
+	q    float64
+}
+
+// parseAccept ..
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+		if mime == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mime: mime, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the q value of the most specific matching range
+func acceptQuality(ranges []acceptRange, mime string) float64 {
+	if len(ranges) == 0 {
+		return 1
+	}
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mime == mime:
+			s = 2
+		case r.mime == strings.SplitN(mime, "/", 2)[0]+"/*":
+			s = 1
+		case r.mime == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,151 @@
+package himage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatMime(t *testing.T) {
+	cases := map[string]string{
+		"jpg":        "image/jpeg",
+		"JPEG":       "image/jpeg",
+		"image/jpg":  "image/jpeg",
+		"png":        "image/png",
+		"image/tiff": "image/tiff",
+	}
+
+	for format, mime := range cases {
+		if m, err := FormatMime(format); err != nil || m != mime {
+			t.Errorf("%s: mime %s is not valid", format, m)
+		}
+	}
+
+	if _, err := FormatMime("webm"); err == nil {
+		t.Error(errors.New("unsupported format should be rejected"))
+	}
+}
+
+func TestHimageInspect(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).Inspect()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if !hImage.Detail.Inspected || hImage.Detail.Alpha {
+		t.Error(errors.New("inspect detail is not valid"))
+	}
+
+	if hImage.Detail.Colors != INSPECT_COLORS {
+		t.Error(errors.New("photo colors are not valid"))
+	}
+}
+
+func TestChooseFormat(t *testing.T) {
+	photo := Detail{Mime: "image/png", Inspected: true, Colors: INSPECT_COLORS}
+	graphic := Detail{Mime: "image/jpeg", Inspected: true, Colors: 200}
+	tiny := Detail{Mime: "image/png", Inspected: true, Colors: 4}
+	alpha := Detail{Mime: "image/png", Inspected: true, Colors: INSPECT_COLORS, Alpha: true}
+	logo := Detail{Mime: "image/png", Inspected: true, Colors: 3, Alpha: true}
+	animated := Detail{Mime: "image/gif", Inspected: true, Colors: 200, Alpha: true, Frames: 3}
+
+	cases := []struct {
+		detail Detail
+		accept string
+		mime   string
+	}{
+		{photo, "", "image/jpeg"},
+		{photo, "image/png,image/*;q=0.5", "image/png"},
+		{graphic, "image/*", "image/png"},
+		{tiny, "*/*", "image/gif"},
+		{tiny, "image/png, image/gif;q=0", "image/png"},
+		{alpha, "image/webp,image/*", "image/png"},
+		{alpha, "image/jpeg", "image/jpeg"},
+		{logo, "*/*", "image/png"},
+		{Detail{Mime: "image/jpeg"}, "image/*", "image/jpeg"},
+		{photo, "text/html", "image/jpeg"},
+		{animated, "image/png,image/gif;q=0.5", "image/gif"},
+		{animated, "image/*", "image/gif"},
+		{animated, "image/png", "image/png"},
+	}
+
+	for index, c := range cases {
+		if mime := ChooseFormat(c.detail, c.accept); mime != c.mime {
+			t.Errorf("case %d: format %s is not valid", index, mime)
+		}
+	}
+}
+
+func TestNewHimageWithPathDecoders(t *testing.T) {
+	cases := map[string]string{
+		"150x103.webp": "image/webp",
+		"150x103.tiff": "image/tiff",
+		"150x103.bmp":  "image/bmp",
+	}
+
+	for name, mime := range cases {
+		hImage := NewHimageWithPath(filepath.Join("test-files", name))
+		if hImage.Error != nil {
+			t.Error(hImage.Error)
+			continue
+		}
+
+		if hImage.Detail.Mime != mime || hImage.Detail.Width != 150 || hImage.Detail.Height != 103 {
+			t.Errorf("%s: detail is not valid", name)
+		}
+	}
+}
+
+func TestHimageResizeWebP(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "150x103.webp")).Resize(Resize{Width: 50})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/png" || filepath.Ext(hImage.tempPath) != ".png" {
+		t.Error(errors.New("webp output should fall back to png"))
+	}
+
+	o := NewHimageWithPath(hImage.tempPath)
+	if o.Detail.Mime != "image/png" || o.Detail.Width != 50 {
+		t.Error(errors.New("output detail is not valid"))
+	}
+}
+
+func TestHimageConvertTIFF(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "150x103.tiff")).Convert("jpg")
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	o := NewHimageWithPath(hImage.tempPath)
+	if o.Detail.Mime != "image/jpeg" {
+		t.Error(errors.New("converted mime is not valid"))
+	}
+}
+
+func TestNewHimageWithPathUnsupported(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dir)
+
+	animated := []byte("RIFF\x16\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x02\x00\x00\x00\x09\x00\x00\x09\x00\x00")
+	p := filepath.Join(dir, "animated.webp")
+	ioutil.WriteFile(p, animated, os.ModePerm)
+
+	hImage := NewHimageWithPath(p)
+	if _, ok := hImage.Error.(*UnsupportedError); !ok {
+		t.Error(errors.New("animated webp should be unsupported"))
+	}
+
+	p = filepath.Join(dir, "text.png")
+	ioutil.WriteFile(p, []byte("not an image"), os.ModePerm)
+
+	hImage = NewHimageWithPath(p)
+	if e, ok := hImage.Error.(*UnsupportedError); !ok || e.Mime != "text/plain; charset=utf-8" {
+		t.Error(errors.New("unknown format should be unsupported"))
+	}
+}
@@ -1,6 +1,7 @@
 package himage
 
 import (
+	"errors"
 	"fmt"
 	"github.com/disintegration/imaging"
 	"github.com/gabriel-vasile/mimetype"
@@ -8,18 +9,15 @@ import (
 	"image"
 	"image/png"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // detail fetch image details (size, resolutions etc.)
 func (i *Himage) detail() *Himage {
-	if i.moved {
-		i.inDetail()
-		return i
-	}
-
-	if i.path != "" {
+	if i.moved || i.path != "" {
 		i.inDetail()
 	} else if i.Multipart != nil {
 		f, err := i.Multipart.Open()
@@ -28,17 +26,12 @@ func (i *Himage) detail() *Himage {
 			return i
 		}
 		defer f.Close()
+		i.Detail.Size = i.Multipart.Size
 		mime, _ := mimetype.DetectReader(f)
 		i.Detail.Mime = mime.String()
 
-		c, _, err := image.DecodeConfig(f)
-		if err != nil {
-			i.Error = err
-			return i
-		}
-		i.Detail.Width = c.Width
-		i.Detail.Height = c.Height
-
+		f.Seek(0, 0)
+		i.decodeConfig(f)
 	} else if i.File != nil {
 		stat, err := i.File.Stat()
 		if err != nil {
@@ -51,13 +44,15 @@ func (i *Himage) detail() *Himage {
 		i.Detail.Mime = mime.String()
 
 		i.File.Seek(0, 0)
-		c, _, err := image.DecodeConfig(i.File)
-		if err != nil {
-			i.Error = err
-			return i
-		}
-		i.Detail.Width = c.Width
-		i.Detail.Height = c.Height
+		i.decodeConfig(i.File)
+	}
+
+	if i.Error == nil && (i.Detail.Width <= 0 || i.Detail.Height <= 0) {
+		i.Error = &UnsupportedError{Mime: i.Detail.Mime, Reason: fmt.Sprintf("invalid resolution %dx%d", i.Detail.Width, i.Detail.Height)}
+	}
+
+	if i.Error == nil {
+		i.frameDetail()
 	}
 
 	return i
@@ -78,21 +73,37 @@ func (i *Himage) inDetail() *Himage {
 	}
 	i.Detail.Size = stat.Size()
 
-	i.File.Seek(0, 0)
-	c, _, err := image.DecodeConfig(f)
+	mime, err := mimetype.DetectFile(i.path)
 	if err != nil {
 		i.Error = err
 		return i
 	}
-	i.Detail.Width = c.Width
-	i.Detail.Height = c.Height
+	i.Detail.Mime = mime.String()
 
-	mime, err := mimetype.DetectFile(i.path)
+	return i.decodeConfig(f)
+}
+
+// decodeConfig reads the resolution, the mime type follows the decoder
+// that recognized the image over the sniffed one
+func (i *Himage) decodeConfig(r io.Reader) *Himage {
+	if i.Detail.Mime == "image/svg+xml" {
+		return i.svgConfig(r)
+	}
+
+	c, format, err := image.DecodeConfig(r)
 	if err != nil {
+		if err == image.ErrFormat {
+			err = &UnsupportedError{Mime: i.Detail.Mime, Reason: "no decoder is registered"}
+		}
 		i.Error = err
 		return i
 	}
-	i.Detail.Mime = mime.String()
+
+	if mime, ok := decoders[format]; ok {
+		i.Detail.Mime = mime
+	}
+	i.Detail.Width = c.Width
+	i.Detail.Height = c.Height
 
 	return i
 }
@@ -102,14 +113,12 @@ func (i *Himage) makeTemp() *Himage {
 	if i.Error != nil {
 		return i
 	}
-	//ext := strings.Split(i.Detail.Mime, "/")[0]*/
-
 	name := uuid.New().String()
 	if i.dst == "" && i.name != "" {
 		name = i.name
 	}
 
-	i.tempPath = filepath.Join(os.TempDir(), fmt.Sprintf("%s.jpg", name))
+	i.tempPath = filepath.Join(os.TempDir(), fmt.Sprintf("%s%s", name, i.extension()))
 	_, err := os.Create(string(os.PathSeparator) + i.tempPath)
 	if err != nil {
 		i.Error = err
@@ -119,6 +128,66 @@ func (i *Himage) makeTemp() *Himage {
 	return i
 }
 
+// extension returns the file extension of the current mime type
+func (i *Himage) extension() string {
+	if ext, ok := formats[i.Detail.Mime]; ok {
+		return ext
+	}
+	if ext, ok := inputs[i.Detail.Mime]; ok {
+		return ext
+	}
+	return ".jpg"
+}
+
+// writeDestination copies the temp file into the destination directory
+func (i *Himage) writeDestination() *Himage {
+	if err := os.MkdirAll(i.dst, os.ModePerm); err != nil {
+		i.Error = err
+		return i
+	}
+
+	name := filepath.Base(i.tempPath)
+	if i.name != "" {
+		name = i.name + i.extension()
+	}
+	output := filepath.Join(i.dst, name)
+	if i.naming == NamingContent {
+		output = filepath.Join(i.dst, "."+uuid.New().String()+i.extension())
+	}
+
+	f, err := os.Open(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+	defer f.Close()
+
+	d, err := os.Create(output)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+	defer d.Close()
+
+	w, h := checksumWriter(d, i.outputChecksum())
+	if err := i.bytesWrite(f, w); err != nil {
+		i.Error = err
+		return i
+	}
+
+	if i.outputChecksum() {
+		i.Detail.OutputSHA256 = checksumHex(h)
+	}
+
+	if i.naming == NamingContent {
+		d.Close()
+		return i.moveContentAddressed(output)
+	}
+	i.output = output
+
+	return i
+}
+
 // makeQuality ..
 func (i *Himage) makeQuality() *Himage {
 	i.quality = make(map[string]interface{})
@@ -148,28 +217,33 @@ func (i *Himage) moveToTemp() *Himage {
 	}
 	defer d.Close()
 
+	w, h := checksumWriter(d, i.checksum&ChecksumSource != 0)
+
 	if i.path != "" {
-		i.movePathToTemp(d)
+		i.movePathToTemp(w)
 	} else if i.Multipart != nil {
-		i.moveMultipartToTemp(d)
+		i.moveMultipartToTemp(w)
 	} else if i.File != nil {
-		i.moveFileToTemp(d)
+		i.moveFileToTemp(w)
+	}
+
+	if i.Error == nil && i.checksum&ChecksumSource != 0 {
+		i.Detail.SourceSHA256 = checksumHex(h)
 	}
 
 	return i
 }
 
 // movePathToTemp ..
-func (i *Himage) movePathToTemp(d *os.File) *Himage {
+func (i *Himage) movePathToTemp(d io.Writer) *Himage {
 	f, err := os.Open(i.path)
 	if err != nil {
 		i.Error = err
 		return i
 	}
 	defer f.Close()
-	f.Seek(0, 0)
-	_, err = io.Copy(d, f)
-	if err != nil {
+
+	if err := i.bytesWrite(f, d); err != nil {
 		i.Error = err
 		return i
 	}
@@ -178,19 +252,14 @@ func (i *Himage) movePathToTemp(d *os.File) *Himage {
 }
 
 // moveMultipartToTemp ..
-func (i *Himage) moveMultipartToTemp(d *os.File) *Himage {
+func (i *Himage) moveMultipartToTemp(d io.Writer) *Himage {
 	f, err := i.Multipart.Open()
 	if err != nil {
 		i.Error = err
 		return i
 	}
+	defer f.Close()
 	f.Seek(0, 0)
-	if i.Detail.Size <= int64(CHUNK_SIZE) {
-		if err := i.bytesCopy(int64(CHUNK_SIZE), f, d); err != nil {
-			i.Error = err
-			return i
-		}
-	}
 
 	if err := i.bytesWrite(f, d); err != nil {
 		i.Error = err
@@ -200,14 +269,9 @@ func (i *Himage) moveMultipartToTemp(d *os.File) *Himage {
 	return i
 }
 
-func (i *Himage) moveFileToTemp(d *os.File) *Himage {
+// moveFileToTemp ..
+func (i *Himage) moveFileToTemp(d io.Writer) *Himage {
 	i.File.Seek(0, 0)
-	if i.Detail.Size <= int64(CHUNK_SIZE) {
-		if err := i.bytesCopy(int64(CHUNK_SIZE), i.File, d); err != nil {
-			i.Error = err
-			return i
-		}
-	}
 
 	if err := i.bytesWrite(i.File, d); err != nil {
 		i.Error = err
@@ -217,21 +281,6 @@ func (i *Himage) moveFileToTemp(d *os.File) *Himage {
 	return i
 }
 
-// bytesCopy ..
-func (i *Himage) bytesCopy(size int64, r io.Reader, w io.Writer) error {
-	buffer := make([]byte, size)
-	_, err := r.Read(buffer)
-	if err != nil {
-		return err
-	}
-
-	_, err = w.Write(buffer)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 // bytesWrite ..
 func (i *Himage) bytesWrite(r io.Reader, w io.Writer) error {
 	reading := true
@@ -255,19 +304,125 @@ func (i *Himage) bytesWrite(r io.Reader, w io.Writer) error {
 	return nil
 }
 
-// save ..
-func (i *Himage) save(im *image.NRGBA) {
+// open returns a reader of the current state of the image, the temp
+// file once moved or the source otherwise
+func (i *Himage) open() (io.ReadCloser, error) {
+	if i.moved {
+		return os.Open(i.tempPath)
+	}
+
+	if i.path != "" {
+		return os.Open(i.path)
+	} else if i.Multipart != nil {
+		return i.Multipart.Open()
+	} else if i.File != nil {
+		if _, err := i.File.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(i.File), nil
+	}
+
+	return nil, errors.New("image source is nil")
+}
+
+// image decodes the current state of the image
+func (i *Himage) image() (image.Image, error) {
+	r, err := i.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if i.Detail.Mime == "image/svg+xml" {
+		data, err := readSVG(r)
+		if err != nil {
+			return nil, err
+		}
+		return renderSVG(data, i.Detail.Width, i.Detail.Height)
+	}
+
+	return imaging.Decode(r)
+}
+
+// transform decodes the temp file, applies fn and saves the result
+func (i *Himage) transform(fn func(src image.Image) *image.NRGBA) *Himage {
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Rasterize(0, 0); i.Error != nil {
+		return i
+	}
+
+	if i.Detail.Mime == "image/gif" && i.Detail.Frames > 1 {
+		return i.transformFrames(fn)
+	}
+
+	src, err := imaging.Open(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	im := fn(src)
+	if i.Error != nil {
+		return i
+	}
+
+	if _, ok := formats[i.Detail.Mime]; !ok {
+		i.retarget("image/png")
+	}
+
+	i.save(im)
+	if i.Error == nil {
+		i.Detail.Width = im.Bounds().Dx()
+		i.Detail.Height = im.Bounds().Dy()
+	}
+
+	return i
+}
+
+// retarget switches the output mime type and the temp file extension
+func (i *Himage) retarget(mime string) {
 	os.Remove(i.tempPath)
+	i.Detail.Mime = mime
+	i.tempPath = strings.TrimSuffix(i.tempPath, filepath.Ext(i.tempPath)) + i.extension()
+}
+
+// encode writes the image in the current mime type with the configured quality
+func (i *Himage) encode(w io.Writer, im image.Image) error {
 	switch i.Detail.Mime {
 	case "image/jpeg", "image/jpg":
-		if err := imaging.Save(im, i.tempPath, imaging.JPEGQuality(i.qJPEG)); err != nil {
-			i.Error = err
-		}
-		break
+		return imaging.Encode(w, im, imaging.JPEG, imaging.JPEGQuality(i.qJPEG))
 	case "image/png":
-		if err := imaging.Save(im, i.tempPath, imaging.PNGCompressionLevel(i.qPNG)); err != nil {
-			i.Error = err
-		}
-		break
+		return imaging.Encode(w, im, imaging.PNG, imaging.PNGCompressionLevel(i.qPNG))
+	case "image/gif":
+		return encodeGIF(w, im)
+	case "image/tiff":
+		return imaging.Encode(w, im, imaging.TIFF)
+	case "image/bmp":
+		return imaging.Encode(w, im, imaging.BMP)
+	}
+
+	return fmt.Errorf("unsupported output format %q", i.Detail.Mime)
+}
+
+// save ..
+func (i *Himage) save(im *image.NRGBA) {
+	os.Remove(i.tempPath)
+	f, err := os.Create(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return
+	}
+	defer f.Close()
+
+	if err := i.encode(f, im); err != nil {
+		i.Error = err
+		return
+	}
+
+	if stat, err := f.Stat(); err == nil {
+		i.Detail.Size = stat.Size()
 	}
 }
@@ -355,68 +355,6 @@ func Test_moveToTempSmallOSFile(t *testing.T) {
 	}
 }
 
-func Test_bytesCopy(t *testing.T) {
-	hImage := new(Himage)
-
-	size := 32 * 1024
-	readerBytes := make([]byte, size)
-	for i := 0; i < size; i++ {
-		readerBytes[i] = 1
-	}
-
-	writesBytes := make([]byte, 0)
-	buff := bytes.NewBuffer(writesBytes)
-
-	err := hImage.bytesCopy(int64(size), bytes.NewReader(readerBytes), buff)
-	if err != nil {
-		t.Error(err)
-	}
-
-	if buff.Len() != size {
-		t.Error(errors.New("erroneous writing operation"))
-	}
-}
-
-func Test_bytesCopyReaderSizeNotValid(t *testing.T) {
-	hImage := new(Himage)
-
-	size := 0
-	readerBytes := make([]byte, size)
-	for i := 0; i < size; i++ {
-		readerBytes[i] = 1
-	}
-
-	writesBytes := make([]byte, 0)
-	buff := bytes.NewBuffer(writesBytes)
-
-	err := hImage.bytesCopy(int64(32*1024), bytes.NewReader(readerBytes), buff)
-	if err == nil {
-		t.Error(errors.New("erroneous reading operation"))
-	}
-
-	if buff.Len() != 0 {
-		t.Error(errors.New("erroneous writing operation"))
-	}
-}
-
-func Test_bytesCopyWriterSizeNotValid(t *testing.T) {
-	hImage := new(Himage)
-
-	size := 32 * 1024
-	readerBytes := make([]byte, size)
-	for i := 0; i < size; i++ {
-		readerBytes[i] = 1
-	}
-
-	f, _ := ioutil.TempFile(string(os.PathSeparator)+"tmp", "himage")
-	f.Close()
-
-	err := hImage.bytesCopy(int64(size), bytes.NewReader(readerBytes), f)
-	if err == nil {
-		t.Error(errors.New("erroneous writing operation"))
-	}
-}
-
 func Test_bytesWrite(t *testing.T) {
 	hImage := new(Himage)
 
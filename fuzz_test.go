@@ -0,0 +1,225 @@
+//go:build go1.18
+// +build go1.18
+
+package himage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fuzzPixels bounds the decoded size of fuzz inputs
+const fuzzPixels = 1 << 20
+
+// addSeeds adds every file of test-files as a seed
+func addSeeds(f *testing.F) {
+	files, err := ioutil.ReadDir("test-files")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join("test-files", file.Name()))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// fuzzImage opens data through the file source like an uploaded part
+func fuzzImage(t *testing.T, data []byte) *Himage {
+	f, err := ioutil.TempFile("", "himage-fuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHimageWithFile(f)
+}
+
+func FuzzDetail(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hImage := fuzzImage(t, data)
+		if hImage.Error != nil {
+			return
+		}
+
+		if hImage.Detail.Width <= 0 || hImage.Detail.Height <= 0 {
+			t.Fatalf("detail resolution %dx%d is not valid", hImage.Detail.Width, hImage.Detail.Height)
+		}
+
+		if float64(hImage.Detail.Width)*float64(hImage.Detail.Height) > fuzzPixels {
+			return
+		}
+
+		im, err := hImage.Image()
+		if err != nil {
+			return
+		}
+
+		if im.Bounds().Dx() != hImage.Detail.Width || im.Bounds().Dy() != hImage.Detail.Height {
+			t.Fatalf("decoded %v does not match detail %dx%d", im.Bounds().Size(), hImage.Detail.Width, hImage.Detail.Height)
+		}
+	})
+}
+
+// fuzzFormats output formats of FuzzResize selected by a fuzzed byte
+var fuzzFormats = []string{"jpeg", "png", "gif", "tiff", "bmp"}
+
+func FuzzResize(f *testing.F) {
+	files, err := ioutil.ReadDir("test-files")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for k, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join("test-files", file.Name()))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, uint8(k))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, format uint8) {
+		hImage := fuzzImage(t, data)
+		if hImage.Error != nil || float64(hImage.Detail.Width)*float64(hImage.Detail.Height)*float64(hImage.Detail.Frames) > fuzzPixels {
+			return
+		}
+		defer hImage.Finish()
+
+		output := fuzzFormats[int(format)%len(fuzzFormats)]
+		mime, _ := FormatMime(output)
+		hImage.Resize(Resize{Width: 16, Height: 12}).Convert(output)
+		if hImage.Error != nil {
+			return
+		}
+
+		if hImage.Detail.Width != 16 || hImage.Detail.Height != 12 || hImage.Detail.Mime != mime {
+			t.Fatalf("resized detail %dx%d %s is not valid", hImage.Detail.Width, hImage.Detail.Height, hImage.Detail.Mime)
+		}
+
+		stat, err := os.Stat(hImage.tempPath)
+		if err != nil || stat.Size() != hImage.Detail.Size {
+			t.Fatalf("encoded size does not match detail %d", hImage.Detail.Size)
+		}
+
+		o := NewHimageWithPath(hImage.tempPath)
+		if o.Error != nil || o.Detail.Mime != mime || o.Detail.Width != 16 || o.Detail.Height != 12 {
+			t.Fatalf("encoded %s cannot be read back: %v", output, o.Error)
+		}
+	})
+}
+
+// fuzzMultipart opens data through an in memory multipart upload
+func fuzzMultipart(t *testing.T, data []byte) *Himage {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(int64(len(data)) + 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		form.RemoveAll()
+	})
+
+	return NewHimageWithMultipart(form.File["file"][0])
+}
+
+func FuzzMultipart(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hImage := fuzzMultipart(t, data)
+		if hImage.Error != nil {
+			return
+		}
+		defer hImage.Finish()
+
+		if hImage.Detail.Width <= 0 || hImage.Detail.Height <= 0 {
+			t.Fatalf("detail resolution %dx%d is not valid", hImage.Detail.Width, hImage.Detail.Height)
+		}
+
+		if hImage.Detail.Size != int64(len(data)) {
+			t.Fatalf("detail size %d does not match %d", hImage.Detail.Size, len(data))
+		}
+
+		if hImage.SetDestination(t.TempDir()).Move(); hImage.Error != nil || hImage.Detail.Mime == "image/svg+xml" {
+			return
+		}
+
+		stat, err := os.Stat(hImage.tempPath)
+		if err != nil || stat.Size() != int64(len(data)) {
+			t.Fatalf("moved size does not match %d", len(data))
+		}
+	})
+}
+
+func FuzzSanitizeSVG(f *testing.F) {
+	data, err := ioutil.ReadFile(filepath.Join("test-files", "120x60.svg"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add([]byte(`<svg viewBox="0 0 10 5"><a href="javascript:x"><use href="#a"/></a></svg>`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := SanitizeSVG(data)
+		if err != nil {
+			return
+		}
+
+		lower := strings.ToLower(string(out))
+		if strings.Contains(lower, "<script") || strings.Contains(lower, "javascript:") {
+			t.Fatalf("sanitized svg keeps scripts: %s", out)
+		}
+
+		if _, err := SanitizeSVG(out); err != nil {
+			t.Fatalf("sanitized svg cannot be sanitized again: %s", err)
+		}
+	})
+}
+
+func FuzzParsePipeline(f *testing.F) {
+	f.Add([]byte(`{"steps": [{"op": "resize", "width": 10, "anchor": "top"}, {"op": "convert", "format": "png"}]}`))
+	f.Add([]byte("steps:\n  - op: crop\n    width: 4\n    height: 4\n  - op: pad\n    top: 2\n    color: \"#fff\"\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, parse := range []func([]byte) (*Pipeline, error){ParsePipelineJSON, ParsePipelineYAML} {
+			p, err := parse(data)
+			if err != nil {
+				continue
+			}
+
+			if err := p.Valid(); err != nil {
+				t.Fatalf("parsed pipeline is not valid: %s", err)
+			}
+		}
+	})
+}
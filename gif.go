@@ -0,0 +1,393 @@
+package himage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// errStopFrames stops frame compositing early
+var errStopFrames = errors.New("stop frames")
+
+// frameDetail fills Detail.Frames, Duration and LoopCount
+func (i *Himage) frameDetail() *Himage {
+	i.Detail.Frames = 1
+	i.Detail.Duration = 0
+	i.Detail.LoopCount = 0
+
+	if i.Detail.Mime == "image/webp" {
+		return i.webpDetail()
+	}
+
+	if i.Detail.Mime != "image/gif" {
+		return i
+	}
+
+	r, err := i.open()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+	defer r.Close()
+
+	frames, duration, loop, err := gifInfo(r)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+	i.Detail.Frames = frames
+	i.Detail.Duration = duration
+	i.Detail.LoopCount = loop
+
+	return i
+}
+
+// webpDetail rejects animated WebP, the decoder only reads still images
+func (i *Himage) webpDetail() *Himage {
+	r, err := i.open()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+	defer r.Close()
+
+	header := make([]byte, 21)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return i
+	}
+
+	if string(header[12:16]) == "VP8X" && header[20]&0x02 != 0 {
+		i.Error = &UnsupportedError{Mime: "image/webp", Reason: "animated webp is not supported"}
+	}
+
+	return i
+}
+
+// gifInfo walks the GIF blocks without decoding pixels. LoopCount follows
+// image/gif: 0 loops forever, -1 plays once.
+func gifInfo(r io.Reader) (int, time.Duration, int, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, 0, 0, err
+	}
+
+	if string(header[:6]) != "GIF87a" && string(header[:6]) != "GIF89a" {
+		return 0, 0, 0, errors.New("gif: invalid header")
+	}
+
+	if header[10]&0x80 != 0 {
+		if _, err := br.Discard(3 * (1 << ((header[10] & 0x07) + 1))); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	frames, delay, loop := 0, 0, -1
+	var duration time.Duration
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		switch b {
+		case 0x21:
+			label, err := br.ReadByte()
+			if err != nil {
+				return 0, 0, 0, err
+			}
+
+			blocks, err := gifSubBlocks(br, label == 0xF9 || label == 0xFF)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+
+			if label == 0xF9 && len(blocks) > 0 && len(blocks[0]) >= 3 {
+				delay = int(blocks[0][1]) | int(blocks[0][2])<<8
+			}
+
+			if label == 0xFF && len(blocks) > 1 && string(blocks[0]) == "NETSCAPE2.0" && len(blocks[1]) >= 3 && blocks[1][0] == 1 {
+				loop = int(blocks[1][1]) | int(blocks[1][2])<<8
+			}
+		case 0x2C:
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(br, descriptor); err != nil {
+				return 0, 0, 0, err
+			}
+
+			if descriptor[8]&0x80 != 0 {
+				if _, err := br.Discard(3 * (1 << ((descriptor[8] & 0x07) + 1))); err != nil {
+					return 0, 0, 0, err
+				}
+			}
+
+			if _, err := br.ReadByte(); err != nil {
+				return 0, 0, 0, err
+			}
+
+			if _, err := gifSubBlocks(br, false); err != nil {
+				return 0, 0, 0, err
+			}
+
+			frames++
+			duration += time.Duration(delay) * 10 * time.Millisecond
+			delay = 0
+		case 0x3B:
+			if frames == 0 {
+				return 0, 0, 0, errors.New("gif: no frames")
+			}
+			return frames, duration, loop, nil
+		default:
+			return 0, 0, 0, fmt.Errorf("gif: unknown block 0x%02x", b)
+		}
+	}
+}
+
+// gifSubBlocks reads data sub-blocks up to the terminator, keeping them when asked
+func gifSubBlocks(br *bufio.Reader, keep bool) ([][]byte, error) {
+	blocks := make([][]byte, 0)
+	for {
+		n, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			return blocks, nil
+		}
+
+		if !keep {
+			if _, err := br.Discard(int(n)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		block := make([]byte, n)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// compositeFrames renders every frame onto the logical screen honoring
+// the disposal methods and calls each with the full canvas
+func compositeFrames(g *gif.GIF, each func(k int, canvas *image.NRGBA) error) error {
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for k, frame := range g.Image {
+		disposal := byte(0)
+		if k < len(g.Disposal) {
+			disposal = g.Disposal[k]
+		}
+
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if err := each(k, canvas); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return nil
+}
+
+// decodeFrames decodes every frame, animations whose frames would
+// composite over MAX_PIXELS are rejected before decoding
+func (i *Himage) decodeFrames() (*gif.GIF, error) {
+	if float64(i.Detail.Frames)*float64(i.Detail.Width)*float64(i.Detail.Height) > float64(MAX_PIXELS) {
+		return nil, fmt.Errorf("%d frame(s) of %dx%d exceed %d pixels", i.Detail.Frames, i.Detail.Width, i.Detail.Height, MAX_PIXELS)
+	}
+
+	f, err := os.Open(i.tempPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return gif.DecodeAll(f)
+}
+
+// transformFrames applies fn to every composited frame, keeping the
+// delays, disposal methods, loop count and frame palettes
+func (i *Himage) transformFrames(fn func(src image.Image) *image.NRGBA) *Himage {
+	g, err := i.decodeFrames()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	out := &gif.GIF{
+		Delay:           g.Delay,
+		Disposal:        g.Disposal,
+		LoopCount:       g.LoopCount,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+	err = compositeFrames(g, func(k int, canvas *image.NRGBA) error {
+		im := fn(canvas)
+		if i.Error != nil {
+			return i.Error
+		}
+		out.Image = append(out.Image, paletted(im, g.Image[k].Palette))
+		return nil
+	})
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	out.Config = image.Config{
+		ColorModel: g.Config.ColorModel,
+		Width:      out.Image[0].Bounds().Dx(),
+		Height:     out.Image[0].Bounds().Dy(),
+	}
+
+	return i.saveFrames(out)
+}
+
+// Poster replaces an animated GIF with a still of the frame at index
+func (i *Himage) Poster(index int) *Himage {
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Error != nil {
+		return i
+	}
+
+	if index < 0 || index >= i.Detail.Frames {
+		i.Error = fmt.Errorf("frame %d is out of range, image has %d frame(s)", index, i.Detail.Frames)
+		return i
+	}
+
+	if i.Detail.Mime != "image/gif" || i.Detail.Frames == 1 {
+		return i
+	}
+
+	g, err := i.decodeFrames()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	var poster *image.Paletted
+	err = compositeFrames(g, func(k int, canvas *image.NRGBA) error {
+		if k != index {
+			return nil
+		}
+		poster = paletted(canvas, g.Image[k].Palette)
+		return errStopFrames
+	})
+	if err != nil && err != errStopFrames {
+		i.Error = err
+		return i
+	}
+
+	i.saveFrames(&gif.GIF{
+		Image:     []*image.Paletted{poster},
+		Delay:     []int{0},
+		LoopCount: -1,
+		Config: image.Config{
+			ColorModel: g.Config.ColorModel,
+			Width:      poster.Bounds().Dx(),
+			Height:     poster.Bounds().Dy(),
+		},
+	})
+
+	if i.Error == nil {
+		i.Detail.Frames = 1
+		i.Detail.Duration = 0
+		i.Detail.LoopCount = 0
+	}
+
+	return i
+}
+
+// saveFrames encodes the animation into the temp file
+func (i *Himage) saveFrames(g *gif.GIF) *Himage {
+	buffer := new(bytes.Buffer)
+	if err := gif.EncodeAll(buffer, g); err != nil {
+		i.Error = err
+		return i
+	}
+
+	if err := ioutil.WriteFile(i.tempPath, buffer.Bytes(), os.ModePerm); err != nil {
+		i.Error = err
+		return i
+	}
+
+	i.Detail.Width = g.Config.Width
+	i.Detail.Height = g.Config.Height
+	i.Detail.Size = int64(buffer.Len())
+
+	return i
+}
+
+// encodeGIF encodes a still image, images with at most 256 opaque or
+// fully transparent colors keep their exact palette without dithering
+func encodeGIF(w io.Writer, im image.Image) error {
+	p, ok := exactPalette(im)
+	if !ok {
+		return imaging.Encode(w, im, imaging.GIF)
+	}
+	return gif.Encode(w, paletted(im, p), nil)
+}
+
+// exactPalette returns the distinct colors of im, false when there are
+// more than 256 or a partial alpha that GIF cannot keep
+func exactPalette(im image.Image) (color.Palette, bool) {
+	src := imaging.Clone(im)
+	seen := make(map[color.NRGBA]bool)
+	p := make(color.Palette, 0)
+	for k := 0; k < len(src.Pix); k += 4 {
+		c := color.NRGBA{R: src.Pix[k], G: src.Pix[k+1], B: src.Pix[k+2], A: src.Pix[k+3]}
+		switch c.A {
+		case 0:
+			c = color.NRGBA{}
+		case 0xff:
+		default:
+			return nil, false
+		}
+		if seen[c] {
+			continue
+		}
+		if len(p) == 256 {
+			return nil, false
+		}
+		seen[c] = true
+		p = append(p, c)
+	}
+
+	if len(p) == 0 {
+		p = append(p, color.NRGBA{})
+	}
+	return p, true
+}
+
+// paletted maps an image onto a palette at the origin
+func paletted(im image.Image, p color.Palette) *image.Paletted {
+	b := im.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), p)
+	draw.Draw(dst, dst.Bounds(), im, b.Min, draw.Src)
+	return dst
+}
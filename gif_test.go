@@ -0,0 +1,224 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_frameDetail(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif"))
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if hImage.Detail.Mime != "image/gif" || hImage.Detail.Width != 100 || hImage.Detail.Height != 80 {
+		t.Error(errors.New("detail is not valid"))
+	}
+
+	if hImage.Detail.Frames != 3 {
+		t.Error(errors.New("frame count is not valid"))
+	}
+
+	if hImage.Detail.Duration != 600*time.Millisecond {
+		t.Error(errors.New("duration is not valid"))
+	}
+
+	if hImage.Detail.LoopCount != 0 {
+		t.Error(errors.New("loop count is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "10x10.png"))
+	if hImage.Detail.Frames != 1 {
+		t.Error(errors.New("still frame count is not valid"))
+	}
+}
+
+func TestHimageResizeAnimated(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).
+		Resize(Resize{Width: 50, Height: 40}).
+		Crop(Crop{Width: 40, Height: 40}).
+		Rotate(90)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	f, _ := os.Open(hImage.tempPath)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.Image) != 3 || g.Config.Width != 40 || g.Config.Height != 40 {
+		t.Error(errors.New("animated frames are not valid"))
+	}
+
+	if g.Delay[2] != 30 || g.Disposal[1] != gif.DisposalBackground || g.LoopCount != 0 {
+		t.Error(errors.New("animation timing is not valid"))
+	}
+
+	if hImage.Detail.Width != 40 || hImage.Detail.Frames != 3 {
+		t.Error(errors.New("detail is not valid"))
+	}
+}
+
+func TestHimagePoster(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).Poster(1)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	f, _ := os.Open(hImage.tempPath)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.Image) != 1 || g.Config.Width != 100 || hImage.Detail.Frames != 1 {
+		t.Error(errors.New("poster is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).Poster(3)
+	if hImage.Error == nil {
+		t.Error(errors.New("out of range frame should fail"))
+	}
+	hImage.Finish()
+}
+
+func TestHimageConvertAnimated(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).Convert("png")
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/png" || hImage.Detail.Frames != 1 {
+		t.Error(errors.New("converted detail is not valid"))
+	}
+}
+
+func TestHimageOptimizeAnimated(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).Optimize(Optimize{})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	f, _ := os.Open(hImage.tempPath)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.Image) != 3 || hImage.Detail.Frames != 3 {
+		t.Error(errors.New("optimized frames are not valid"))
+	}
+
+	over := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).Optimize(Optimize{MaxSize: 100})
+	defer over.Finish()
+	if over.Error == nil {
+		t.Error(errors.New("animated max size is not valid"))
+	}
+}
+
+func TestHimageConvertGIFExact(t *testing.T) {
+	flat := color.NRGBA{R: 58, G: 123, B: 213, A: 255}
+	path := writeTestImage(t, func(im *image.NRGBA) {
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 30; x++ {
+				im.SetNRGBA(x, y, flat)
+			}
+		}
+	})
+
+	hImage := NewHimageWithPath(path).Convert("gif")
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	im, err := hImage.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			want := flat
+			if x >= 30 {
+				want = color.NRGBA{}
+			}
+			if c := color.NRGBAModel.Convert(im.At(x, y)).(color.NRGBA); c != want {
+				t.Fatalf("pixel %d,%d %v is not valid, want %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+// writeFramesGIF writes an animation of 1x1 frames on a large logical screen
+func writeFramesGIF(t *testing.T, width, height, frames int) string {
+	g := &gif.GIF{Config: image.Config{ColorModel: color.Palette{color.Black, color.White}, Width: width, Height: height}}
+	for k := 0; k < frames; k++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 1)
+	}
+
+	f, err := ioutil.TempFile("", "himage*.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestHimageAnimatedPixelLimit(t *testing.T) {
+	path := writeFramesGIF(t, 9000, 9000, 200)
+	if stat, err := os.Stat(path); err != nil || stat.Size() > 8*1024 {
+		t.Fatal(errors.New("animation fixture is not valid"))
+	}
+
+	hImage := NewHimageWithPath(path)
+	if hImage.Error != nil || hImage.Detail.Frames != 200 {
+		t.Fatal(errors.New("animation detail is not valid"))
+	}
+
+	p, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "rotate", "angle": 1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FramePixels(hImage.Detail) <= int64(MAX_PIXELS) {
+		t.Error(errors.New("frame pixels are not valid"))
+	}
+	if p.Apply(hImage); hImage.Error == nil {
+		t.Error(errors.New("pipeline over the frame pixel limit should be rejected"))
+	}
+	hImage.Finish()
+
+	hImage = NewHimageWithPath(path).Rotate(1)
+	if hImage.Error == nil {
+		t.Error(errors.New("transform over the frame pixel limit should be rejected"))
+	}
+	hImage.Finish()
+
+	hImage = NewHimageWithPath(path).Poster(0)
+	if hImage.Error == nil {
+		t.Error(errors.New("poster over the frame pixel limit should be rejected"))
+	}
+	hImage.Finish()
+}
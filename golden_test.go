@@ -0,0 +1,40 @@
+package himage_test
+
+import (
+	"github.com/streetbyters/himage"
+	"github.com/streetbyters/himage/himagetest"
+	"path/filepath"
+	"testing"
+)
+
+// goldenTolerance absorbs decoder rounding differences
+var goldenTolerance = himagetest.Tolerance{MinSSIM: 0.995, PixelThreshold: 2, MaxDiffPixels: 100}
+
+func TestGolden(t *testing.T) {
+	cases := map[string]func(i *himage.Himage) *himage.Himage{
+		"resize": func(i *himage.Himage) *himage.Himage {
+			return i.Resize(himage.Resize{Width: 160, Height: 120, Anchor: himage.Center})
+		},
+		"crop-rotate": func(i *himage.Himage) *himage.Himage {
+			return i.Crop(himage.Crop{Width: 200, Height: 200, Anchor: himage.TopLeft}).Rotate(30)
+		},
+		"circle-sepia": func(i *himage.Himage) *himage.Himage {
+			return i.Resize(himage.Resize{Width: 96}).Sepia().CircleMask()
+		},
+		"border-sharpen": func(i *himage.Himage) *himage.Himage {
+			return i.Resize(himage.Resize{Width: 128, Sharpen: himage.Sharpen{Sigma: 0.6}}).Border(4, nil)
+		},
+	}
+
+	for name, fn := range cases {
+		t.Run(name, func(t *testing.T) {
+			hImage := fn(himage.NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")))
+			if hImage.Error != nil {
+				t.Fatal(hImage.Error)
+			}
+			defer hImage.Finish()
+
+			himagetest.AssertGolden(t, hImage, filepath.Join("test-files", "golden", name+".png"), goldenTolerance)
+		})
+	}
+}
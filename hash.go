@@ -0,0 +1,297 @@
+package himage
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// HashKind is a perceptual hash algorithm
+type HashKind int
+
+// Perceptual hash algorithms, all of them produce 64 bits.
+const (
+	// AHash compares an 8x8 grayscale thumbnail with its mean
+	AHash HashKind = iota
+	// DHash compares horizontally adjacent pixels of a 9x8 thumbnail
+	DHash
+	// PHash compares the low DCT frequencies of a 32x32 thumbnail with their median
+	PHash
+)
+
+// hashKindNames ..
+var hashKindNames = []string{"ahash", "dhash", "phash"}
+
+// String ..
+func (k HashKind) String() string {
+	if k < 0 || int(k) >= len(hashKindNames) {
+		return fmt.Sprintf("HashKind(%d)", int(k))
+	}
+	return hashKindNames[k]
+}
+
+// Hash is a 64 bit perceptual hash
+type Hash uint64
+
+// String ..
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Distance returns the Hamming distance of two hashes of the same kind
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Hash computes the perceptual hash of the current image, the first
+// frame of animations
+func (i *Himage) Hash(kind HashKind) (Hash, error) {
+	if i.Error != nil {
+		return 0, i.Error
+	}
+
+	src, err := i.image()
+	if err != nil {
+		return 0, err
+	}
+
+	return ImageHash(src, kind)
+}
+
+// ImageHash computes the perceptual hash of an image
+func ImageHash(src image.Image, kind HashKind) (Hash, error) {
+	switch kind {
+	case AHash:
+		return aHash(src), nil
+	case DHash:
+		return dHash(src), nil
+	case PHash:
+		return pHash(src), nil
+	}
+
+	return 0, fmt.Errorf("unknown hash kind %d", int(kind))
+}
+
+// grayPixels returns the luminance of a w x h thumbnail in row order
+func grayPixels(src image.Image, w, h int) []float64 {
+	thumb := imaging.Resize(imaging.Grayscale(src), w, h, imaging.Box)
+	pixels := make([]float64, 0, w*h)
+	for k := 0; k < len(thumb.Pix); k += 4 {
+		pixels = append(pixels, float64(thumb.Pix[k]))
+	}
+	return pixels
+}
+
+// aHash ..
+func aHash(src image.Image) Hash {
+	pixels := grayPixels(src, 8, 8)
+	mean := 0.0
+	for _, p := range pixels {
+		mean += p
+	}
+	mean /= float64(len(pixels))
+
+	var h Hash
+	for k, p := range pixels {
+		if p > mean {
+			h |= 1 << uint(k)
+		}
+	}
+	return h
+}
+
+// dHash ..
+func dHash(src image.Image) Hash {
+	pixels := grayPixels(src, 9, 8)
+
+	var h Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] > pixels[y*9+x+1] {
+				h |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return h
+}
+
+// pHash ..
+func pHash(src image.Image) Hash {
+	const size = 32
+	pixels := grayPixels(src, size, size)
+
+	rows := make([]float64, size*size)
+	for y := 0; y < size; y++ {
+		copy(rows[y*size:], dct(pixels[y*size:(y+1)*size]))
+	}
+
+	coefficients := make([]float64, 0, 64)
+	column := make([]float64, size)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < size; y++ {
+			column[y] = rows[y*size+x]
+		}
+		c := dct(column)
+		for y := 0; y < 8; y++ {
+			coefficients = append(coefficients, c[y])
+		}
+	}
+
+	// the DC coefficient is the mean brightness and is left out of the median
+	sorted := append([]float64{}, coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var h Hash
+	for k, c := range coefficients {
+		if c > median {
+			h |= 1 << uint(k)
+		}
+	}
+	return h
+}
+
+// dct is an unnormalized one dimensional DCT-II
+func dct(values []float64) []float64 {
+	n := len(values)
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		sum := 0.0
+		for x, v := range values {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(x)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+	return out
+}
+
+// Duplicate is a DuplicateIndex query match
+type Duplicate struct {
+	ID       string
+	Hash     Hash
+	Distance int
+}
+
+// DuplicateIndex finds near-duplicate hashes within a Hamming distance
+// using a BK-tree. It is safe for concurrent use.
+type DuplicateIndex struct {
+	Kind HashKind
+
+	mu   sync.RWMutex
+	root *bkNode
+	size int
+}
+
+// bkNode keeps the ids sharing a hash and children keyed by distance
+type bkNode struct {
+	hash     Hash
+	ids      []string
+	children map[int]*bkNode
+}
+
+// NewDuplicateIndex ..
+func NewDuplicateIndex(kind HashKind) *DuplicateIndex {
+	return &DuplicateIndex{Kind: kind}
+}
+
+// Len returns the number of indexed ids
+func (d *DuplicateIndex) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.size
+}
+
+// Add indexes a hash of the index kind under id
+func (d *DuplicateIndex) Add(id string, hash Hash) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.size++
+	if d.root == nil {
+		d.root = &bkNode{hash: hash, ids: []string{id}}
+		return
+	}
+
+	node := d.root
+	for {
+		distance := Distance(node.hash, hash)
+		if distance == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+
+		child, ok := node.children[distance]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[distance] = &bkNode{hash: hash, ids: []string{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// AddImage hashes the image with the index kind and indexes it under id
+func (d *DuplicateIndex) AddImage(id string, i *Himage) error {
+	hash, err := i.Hash(d.Kind)
+	if err != nil {
+		return err
+	}
+
+	d.Add(id, hash)
+	return nil
+}
+
+// Query returns the ids within threshold of hash, closest first
+func (d *DuplicateIndex) Query(hash Hash, threshold int) []Duplicate {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	matches := make([]Duplicate, 0)
+	if d.root == nil {
+		return matches
+	}
+
+	stack := []*bkNode{d.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := Distance(node.hash, hash)
+		if distance <= threshold {
+			for _, id := range node.ids {
+				matches = append(matches, Duplicate{ID: id, Hash: node.hash, Distance: distance})
+			}
+		}
+
+		for k, child := range node.children {
+			if k >= distance-threshold && k <= distance+threshold {
+				stack = append(stack, child)
+			}
+		}
+	}
+
+	sort.Slice(matches, func(x, y int) bool {
+		if matches[x].Distance != matches[y].Distance {
+			return matches[x].Distance < matches[y].Distance
+		}
+		return matches[x].ID < matches[y].ID
+	})
+
+	return matches
+}
+
+// QueryImage hashes the image with the index kind and queries it
+func (d *DuplicateIndex) QueryImage(i *Himage, threshold int) ([]Duplicate, error) {
+	hash, err := i.Hash(d.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Query(hash, threshold), nil
+}
@@ -0,0 +1,74 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageHash(t *testing.T) {
+	original := NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg"))
+	resized := NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).
+		Resize(Resize{Width: 320}).
+		Convert("png")
+	if resized.Error != nil {
+		t.Fatal(resized.Error)
+	}
+	defer resized.Finish()
+	other := NewHimageWithPath(filepath.Join("test-files", "850x566.png"))
+
+	for _, kind := range []HashKind{AHash, DHash, PHash} {
+		a, err := original.Hash(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := resized.Hash(kind)
+		c, _ := other.Hash(kind)
+
+		if Distance(a, b) > 6 {
+			t.Error(fmt.Errorf("%s of a resized copy is not valid", kind))
+		}
+		if Distance(a, c) < 12 {
+			t.Error(fmt.Errorf("%s of a different image is not valid", kind))
+		}
+	}
+
+	if _, err := original.Hash(HashKind(9)); err == nil {
+		t.Error(errors.New("unknown hash kind should fail"))
+	}
+}
+
+func TestDuplicateIndex(t *testing.T) {
+	index := NewDuplicateIndex(PHash)
+	for k, hash := range []Hash{0x0, 0x1, 0x3, 0xff, 0xffff, 0x1} {
+		index.Add(fmt.Sprintf("id-%d", k), hash)
+	}
+
+	if index.Len() != 6 {
+		t.Error(errors.New("index length is not valid"))
+	}
+
+	matches := index.Query(0x1, 1)
+	if len(matches) != 4 || matches[0].ID != "id-1" || matches[1].ID != "id-5" || matches[3].Distance != 1 {
+		t.Error(errors.New("index query is not valid"))
+	}
+
+	if len(index.Query(0xffff, 0)) != 1 || len(index.Query(0xffff, 64)) != 6 {
+		t.Error(errors.New("index threshold is not valid"))
+	}
+
+	if err := index.AddImage("photo", NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg"))); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).Resize(Resize{Width: 200})
+	defer upload.Finish()
+	matches, err := index.QueryImage(upload, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 || matches[0].ID != "photo" {
+		t.Error(errors.New("near duplicate is not found"))
+	}
+}
@@ -1,25 +1,61 @@
 package himage
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/disintegration/imaging"
 	"image"
+	"image/color"
 	"image/png"
+	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"os"
+	"time"
 )
 
+// Detail ..
+type Detail struct {
+	Width  int
+	Height int
+	Mime   string
+	Size   int64
+	// Inspected reports whether Alpha and Colors were filled by Inspect
+	Inspected bool
+	// Alpha reports whether any pixel is not fully opaque
+	Alpha bool
+	// Colors is the distinct color count of a sample, capped at INSPECT_COLORS
+	Colors int
+	// Frames is the frame count, one for still images
+	Frames int
+	// Duration is the total animation delay
+	Duration time.Duration
+	// LoopCount follows image/gif: 0 loops forever, -1 plays once
+	LoopCount int
+	// ViewBox is the user coordinate system of SVG images
+	ViewBox ViewBox
+	// SourceSHA256 is the hex digest of the source, set with ChecksumSource
+	SourceSHA256 string
+	// OutputSHA256 is the hex digest of the written result, set with
+	// ChecksumOutput or NamingContent
+	OutputSHA256 string
+	// Palette is filled by Palette, most populated color first
+	Palette []PaletteColor
+	// AverageColor is filled by Palette
+	AverageColor color.NRGBA
+	// Tone is filled by Palette
+	Tone Tone
+	// Analysis is filled by Analyze
+	Analysis *Analysis
+}
+
 // Himage ..
 type Himage struct {
-	Multipart *multipart.FileHeader
-	File      *os.File
-	Error     error
-	Detail    struct {
-		Width  int
-		Height int
-		Mime   string
-		Size   int64
-	}
+	Multipart    *multipart.FileHeader
+	File         *os.File
+	Error        error
+	Detail       Detail
 	path         string
 	dst          string
 	quality      map[string]interface{}
@@ -30,6 +66,11 @@ type Himage struct {
 	optimized    bool
 	tempPath     string
 	name         string
+	output       string
+	icons        []string
+	alphaPolicy  AlphaPolicy
+	checksum     Checksum
+	naming       Naming
 	removeOrigin bool
 }
 
@@ -79,6 +120,24 @@ func (i *Himage) SetDestination(p string) *Himage {
 	return i
 }
 
+// SetChecksum ..
+func (i *Himage) SetChecksum(checksum Checksum) *Himage {
+	i.checksum = checksum
+	return i
+}
+
+// SetNaming ..
+func (i *Himage) SetNaming(naming Naming) *Himage {
+	i.naming = naming
+	return i
+}
+
+// SetAlphaPolicy ..
+func (i *Himage) SetAlphaPolicy(policy AlphaPolicy) *Himage {
+	i.alphaPolicy = policy
+	return i
+}
+
 // SetQuality ..
 func (i *Himage) SetQuality(q interface{}) *Himage {
 	switch i.Detail.Mime {
@@ -95,7 +154,7 @@ func (i *Himage) SetQuality(q interface{}) *Himage {
 	return i
 }
 
-// Move ..
+// Move copies the source into a temp file where operations are applied
 func (i *Himage) Move() *Himage {
 	if i.Error != nil {
 		return i
@@ -106,8 +165,22 @@ func (i *Himage) Move() *Himage {
 		return i
 	}
 
+	return i.move()
+}
+
+// move is Move without a destination, for results streamed by WriteTo
+// or Image
+func (i *Himage) move() *Himage {
+	if i.Error != nil {
+		return i
+	}
+
 	i.moveToTemp()
 
+	if i.Error == nil && i.Detail.Mime == "image/svg+xml" {
+		i.sanitizeTemp()
+	}
+
 	if i.Error == nil {
 		i.moved = true
 	}
@@ -117,7 +190,7 @@ func (i *Himage) Move() *Himage {
 // Resize ..
 func (i *Himage) Resize(option Resize) *Himage {
 	if !i.moved {
-		i.Move()
+		i.move()
 	}
 
 	if i.Error != nil {
@@ -129,56 +202,315 @@ func (i *Himage) Resize(option Resize) *Himage {
 		return i
 	}
 
+	width, height := option.dimensions(i.Detail.Width, i.Detail.Height)
+	if w, h := option.cover(float64(i.Detail.Width), float64(i.Detail.Height)); w*h > float64(MAX_PIXELS) {
+		i.Error = fmt.Errorf("resize to %.0fx%.0f exceeds %d pixels", w, h, MAX_PIXELS)
+		return i
+	}
+
+	sharpen, ok := option.sharpen()
+	if i.Detail.Mime == "image/svg+xml" && option.Anchor == 0 {
+		// rendering under the intrinsic size is a downscale as well
+		srcW, srcH := i.Detail.Width, i.Detail.Height
+		if i.Rasterize(width, height); i.Error != nil {
+			return i
+		}
+		if ok && (i.Detail.Width < srcW || i.Detail.Height < srcH) {
+			i.transform(func(src image.Image) *image.NRGBA {
+				return unsharp(src, sharpen)
+			})
+		}
+		if i.Error == nil {
+			i.resized = true
+		}
+		return i
+	}
+
+	if i.Detail.Mime == "image/svg+xml" {
+		// render at the fill size instead of the intrinsic size
+		w, h := option.cover(float64(i.Detail.Width), float64(i.Detail.Height))
+		if i.Rasterize(int(w), int(h)); i.Error != nil {
+			return i
+		}
+	}
+
+	i.transform(func(src image.Image) *image.NRGBA {
+		var im *image.NRGBA
+		if option.Anchor > 0 {
+			im = imaging.Fill(src, width, height, imaging.Anchor(option.Anchor), imaging.Lanczos)
+		} else {
+			im = imaging.Resize(src, width, height, imaging.Lanczos)
+		}
+
+		downscaled := im.Bounds().Dx() < src.Bounds().Dx() || im.Bounds().Dy() < src.Bounds().Dy()
+		if ok && downscaled {
+			return unsharp(im, sharpen)
+		}
+		return im
+	})
+
+	if i.Error == nil {
+		i.resized = true
+	}
+
+	return i
+}
+
+// Crop ..
+func (i *Himage) Crop(option Crop) *Himage {
+	if err := option.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.CropAnchor(src, option.Width, option.Height, imaging.Anchor(option.Anchor))
+	})
+}
+
+// Rotate rotates the image counter-clockwise by angle degrees, uncovered
+// areas are transparent
+func (i *Himage) Rotate(angle float64) *Himage {
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return imaging.Rotate(src, angle, color.Transparent)
+	})
+}
+
+// Convert re-encodes the image to the given format name (png, jpg ..) or mime type
+func (i *Himage) Convert(format string) *Himage {
+	mime, err := FormatMime(format)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	if mime != "image/gif" && i.Detail.Frames > 1 {
+		i.Detail.Frames = 1
+		i.Detail.Duration = 0
+		i.Detail.LoopCount = 0
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		i.retarget(mime)
+		return imaging.Clone(src)
+	})
+}
+
+// Optimize re-encodes the image with the best compression, lowering the
+// JPEG quality down to MinQuality until the result fits in MaxSize. With
+// MinSSIM the quality is not lowered below the SSIM floor, and without
+// MaxSize the lowest quality keeping the floor is used. The original bytes
+// are kept when the re-encode is not smaller.
+func (i *Himage) Optimize(option Optimize) *Himage {
+	if err := option.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Rasterize(0, 0); i.Error != nil {
+		return i
+	}
+
+	// animations keep their encoding, re-encoding the composited frames
+	// would not make them smaller
+	if i.Detail.Mime == "image/gif" && i.Detail.Frames > 1 {
+		if option.MaxSize > 0 && i.Detail.Size > option.MaxSize {
+			i.Error = fmt.Errorf("image cannot be optimized under %d bytes", option.MaxSize)
+			return i
+		}
+		i.optimized = true
+		return i
+	}
+
 	src, err := imaging.Open(i.tempPath)
 	if err != nil {
 		i.Error = err
 		return i
 	}
 
-	width := option.Width
-	height := option.Height
+	stat, err := os.Stat(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	// a re-encode that is not smaller keeps the original bytes
+	original := stat.Size()
+	if _, ok := formats[i.Detail.Mime]; !ok {
+		i.retarget("image/png")
+		original = -1
+	}
 
-	if option.Ratio > 0 {
-		if option.WidthOriented {
-			if option.Maximize {
-				width = option.Width + (option.Width / option.Ratio)
-			} else {
-				width = option.Width - (option.Width / option.Ratio)
+	buffer := new(bytes.Buffer)
+	switch i.Detail.Mime {
+	case "image/jpg", "image/jpeg":
+		floor := 0
+		if option.MinSSIM > 0 {
+			if floor, err = i.qualityFloor(src, option); err != nil {
+				i.Error = err
+				return i
 			}
 		}
 
-		if option.HeightOriented {
-			if option.Maximize {
-				width = option.Height + (option.Height / option.Ratio)
-			} else {
-				width = option.Height - (option.Height / option.Ratio)
+		if option.MaxSize > 0 {
+			min, max, best := option.MinQuality, i.qJPEG, -1
+			if min < floor {
+				min = floor
+			}
+			if min <= 0 {
+				min = 1
+			}
+			for min <= max {
+				q := (min + max) / 2
+				buffer.Reset()
+				if err := imaging.Encode(buffer, src, imaging.JPEG, imaging.JPEGQuality(q)); err != nil {
+					i.Error = err
+					return i
+				}
+				if int64(buffer.Len()) <= option.MaxSize {
+					best = q
+					min = q + 1
+				} else {
+					max = q - 1
+				}
+			}
+			if best > 0 {
+				i.SetQuality(best)
 			}
+		} else if floor > 0 {
+			i.SetQuality(floor)
 		}
+		break
+	case "image/png":
+		i.SetQuality(png.BestCompression)
+		break
 	}
 
-	var im *image.NRGBA
+	buffer.Reset()
+	if err := i.encode(buffer, src); err != nil {
+		i.Error = err
+		return i
+	}
 
-	if option.Anchor > 0 {
-		im = imaging.Fill(src, width, height, imaging.Anchor(option.Anchor), imaging.Lanczos)
-	} else {
-		im = imaging.Resize(src, width, height, imaging.Lanczos)
+	if original >= 0 && int64(buffer.Len()) >= original {
+		if option.MaxSize > 0 && original > option.MaxSize {
+			i.Error = fmt.Errorf("image cannot be optimized under %d bytes", option.MaxSize)
+			return i
+		}
+		i.Detail.Size = original
+		i.optimized = true
+		return i
 	}
 
-	i.save(im)
+	if option.MaxSize > 0 && int64(buffer.Len()) > option.MaxSize {
+		i.Error = fmt.Errorf("image cannot be optimized under %d bytes", option.MaxSize)
+		return i
+	}
 
-	if i.Error != nil {
-		i.resized = true
+	if err := ioutil.WriteFile(i.tempPath, buffer.Bytes(), 0644); err != nil {
+		i.Error = err
+		return i
 	}
+	i.Detail.Size = int64(buffer.Len())
+	i.optimized = true
 
 	return i
 }
 
-// Run ..
+// qualityFloor returns the lowest JPEG quality from MinQuality up to the
+// current quality whose SSIM to src reaches MinSSIM
+func (i *Himage) qualityFloor(src image.Image, option Optimize) (int, error) {
+	reference := imaging.Clone(src)
+	min, max, floor := option.MinQuality, i.qJPEG, i.qJPEG
+	if min <= 0 {
+		min = 1
+	}
+
+	buffer := new(bytes.Buffer)
+	for min <= max {
+		q := (min + max) / 2
+		buffer.Reset()
+		if err := imaging.Encode(buffer, src, imaging.JPEG, imaging.JPEGQuality(q)); err != nil {
+			return 0, err
+		}
+
+		encoded, err := imaging.Decode(buffer)
+		if err != nil {
+			return 0, err
+		}
+
+		if ssim(reference, imaging.Clone(encoded)) >= option.MinSSIM {
+			floor = q
+			max = q - 1
+		} else {
+			min = q + 1
+		}
+	}
+
+	return floor, nil
+}
+
+// WriteTo streams the processed image to w
+func (i *Himage) WriteTo(w io.Writer) (int64, error) {
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Error != nil {
+		return 0, i.Error
+	}
+
+	f, err := os.Open(i.tempPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(w, f)
+}
+
+// Image decodes the current state of the image, the first frame of
+// animations and SVG at its intrinsic size
+func (i *Himage) Image() (image.Image, error) {
+	if i.Error != nil {
+		return nil, i.Error
+	}
+	return i.image()
+}
+
+// Output returns the path written by Finish
+func (i *Himage) Output() string {
+	return i.output
+}
+
+// Finish writes the processed image to the destination when one is set,
+// cleans up the temp file and removes the origin only when RemoveOrigin
+// is set.
 func (i *Himage) Finish() (*Himage, error) {
+	if i.Error == nil && i.dst != "" && !i.moved {
+		i.move()
+	}
+
 	if i.tempPath != "" {
 		defer os.Remove(i.tempPath)
 	}
 
+	if i.Error != nil {
+		return i, i.Error
+	}
+
+	if i.moved && i.dst != "" {
+		i.writeDestination()
+	}
+
+	if i.Error != nil || !i.removeOrigin {
+		return i, i.Error
+	}
+
 	if i.path != "" {
 		i.Error = os.Remove(i.path)
 	} else if i.File != nil {
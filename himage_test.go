@@ -44,3 +44,32 @@ func TestNewHimageWithPathNotExistsFile(t *testing.T) {
 		t.Error(errors.New("invalid file open"))
 	}
 }
+
+func TestHimageMoveWithoutDestination(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).Move()
+	if hImage.Error == nil || hImage.Error.Error() != "destination path is nil" {
+		t.Error(errors.New("missing destination is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "850x566.png")).Resize(Resize{Width: 10, Height: 10})
+	if hImage.Error != nil {
+		t.Error(hImage.Error)
+	}
+	hImage.Finish()
+}
+
+func TestNewHimageWithPathLarge(t *testing.T) {
+	hImage := NewHimageWithPath(writeFramesGIF(t, 20000, 20000, 1))
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	if hImage.Detail.Width != 20000 || hImage.Detail.Height != 20000 {
+		t.Error(errors.New("large detail is not valid"))
+	}
+
+	p := &Pipeline{Steps: []Step{{Op: "rotate", Angle: 90}}}
+	if p.Apply(hImage); hImage.Error == nil {
+		t.Error(errors.New("pipeline over the pixel limit should be rejected"))
+	}
+	hImage.Finish()
+}
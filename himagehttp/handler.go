@@ -0,0 +1,200 @@
+// Package himagehttp serves himage pipelines over HTTP.
+package himagehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/streetbyters/himage"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultCacheControl is used when Handler.CacheControl is empty
+const DefaultCacheControl = "public, max-age=31536000"
+
+// Handler serves GET /{preset or ops}/{path}, loading the source from
+// Storage and streaming the pipeline result. Pipelines without a convert
+// step get their output format negotiated from the Accept header. When Signer is set the
+// path is /{signature}/{preset or ops}/{path} and unsigned requests are
+// rejected with 403.
+type Handler struct {
+	Storage      Storage
+	Signer       *Signer
+	CacheControl string
+	// MaxPixels rejects pipelines reaching more pixels on the source,
+	// summed over the frames of animations. himage.MAX_PIXELS is always
+	// enforced
+	MaxPixels int64
+}
+
+// NewHandler ..
+func NewHandler(storage Storage) *Handler {
+	return &Handler{
+		Storage:      storage,
+		CacheControl: DefaultCacheControl,
+	}
+}
+
+// ServeHTTP ..
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if h.Signer != nil {
+		parts := strings.SplitN(path, "/", 3)
+		if len(parts) != 3 {
+			http.Error(w, ErrSignatureMissing.Error(), http.StatusForbidden)
+			return
+		}
+
+		if err := h.Signer.Verify(parts[0], parts[1], parts[2]); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		path = parts[1] + "/" + parts[2]
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.serve(w, r, parts[0], parts[1])
+}
+
+// serve ..
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, ops string, name string) {
+	p, err := h.pipeline(ops)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := h.Storage.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) || err == ErrInvalidPath {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	negotiate := !converts(p)
+	key := ops
+	if negotiate {
+		w.Header().Set("Vary", "Accept")
+		key += "\x00" + r.Header.Get("Accept")
+	}
+
+	etag := etag(key, name, stat)
+	if notModified(r, etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", h.cacheControl())
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	i := himage.NewHimageWithFile(f)
+	defer i.Finish()
+	if i.Error != nil {
+		http.Error(w, i.Error.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if h.MaxPixels > 0 && p.FramePixels(i.Detail) > h.MaxPixels {
+		http.Error(w, fmt.Sprintf("pipeline output exceeds %d pixels", h.MaxPixels), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if p.Apply(i); i.Error != nil {
+		http.Error(w, i.Error.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if negotiate {
+		if mime := himage.ChooseFormat(i.Inspect().Detail, r.Header.Get("Accept")); mime != i.Detail.Mime {
+			i.Convert(mime)
+		}
+		if i.Error != nil {
+			http.Error(w, i.Error.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", h.cacheControl())
+	w.Header().Set("Content-Type", i.Detail.Mime)
+	w.Header().Set("Content-Length", strconv.FormatInt(i.Detail.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	i.WriteTo(w)
+}
+
+// pipeline resolves a registered preset name or parses an ops string
+func (h *Handler) pipeline(ops string) (*himage.Pipeline, error) {
+	if p, ok := himage.LookupPreset(ops); ok {
+		return p, nil
+	}
+	return ParseOps(ops)
+}
+
+// converts reports whether the pipeline sets the output format itself
+func converts(p *himage.Pipeline) bool {
+	for _, step := range p.Steps {
+		if step.Op == "convert" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl ..
+func (h *Handler) cacheControl() string {
+	if h.CacheControl == "" {
+		return DefaultCacheControl
+	}
+	return h.CacheControl
+}
+
+// etag is derived from the ops and the source file, so unchanged
+// results are answered without processing.
+func etag(ops string, name string, stat os.FileInfo) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d", ops, name, stat.Size(), stat.ModTime().UnixNano())))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified ..
+func notModified(r *http.Request, etag string) bool {
+	match := r.Header.Get("If-None-Match")
+	if match == "" {
+		return false
+	}
+
+	for _, m := range strings.Split(match, ",") {
+		m = strings.TrimPrefix(strings.TrimSpace(m), "W/")
+		if m == etag || m == "*" {
+			return true
+		}
+	}
+	return false
+}
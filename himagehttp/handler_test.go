@@ -0,0 +1,206 @@
+package himagehttp
+
+import (
+	"errors"
+	"github.com/streetbyters/himage"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newTestHandler() *Handler {
+	return NewHandler(Dir(filepath.Join("..", "test-files")))
+}
+
+func TestHandlerOps(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/resize:w=100:h=50:a=top,convert:f=png/640x426.jpeg", nil)
+	newTestHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	if rec.Header().Get("Content-Type") != "image/png" {
+		t.Error(errors.New("content type is not valid"))
+	}
+
+	if rec.Header().Get("ETag") == "" || rec.Header().Get("Cache-Control") != DefaultCacheControl {
+		t.Error(errors.New("cache headers are not valid"))
+	}
+
+	c, format, err := image.DecodeConfig(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if format != "png" || c.Width != 100 || c.Height != 50 {
+		t.Error(errors.New("response image is not valid"))
+	}
+}
+
+func TestHandlerPreset(t *testing.T) {
+	himage.MustRegisterPreset("http-test-thumb", &himage.Pipeline{Steps: []himage.Step{
+		{Op: "resize", Width: 32, Height: 32, Anchor: himage.Top},
+	}})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/http-test-thumb/10x10.png", nil)
+	newTestHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	c, _, err := image.DecodeConfig(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Width != 32 || c.Height != 32 {
+		t.Error(errors.New("response image is not valid"))
+	}
+}
+
+func TestHandlerNotModified(t *testing.T) {
+	h := newTestHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resize:w=10/10x10.png", nil))
+	etag := rec.Header().Get("ETag")
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/resize:w=10/10x10.png", nil)
+	req.Header.Set("If-None-Match", etag)
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Error(errors.New("status is not valid"))
+	}
+
+	if rec.Body.Len() != 0 {
+		t.Error(errors.New("not modified body should be empty"))
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	cases := map[string]int{
+		"/resize:w=10/notfound.png":           http.StatusNotFound,
+		"/resize:w=10/../go.mod":              http.StatusNotFound,
+		"/resize:w=-10/10x10.png":             http.StatusBadRequest,
+		"/rotate:w=10/10x10.png":              http.StatusBadRequest,
+		"/resize:w=10":                        http.StatusNotFound,
+		"/crop:w=10:h=10:a=nowhere/10x10.png": http.StatusBadRequest,
+	}
+
+	for path, code := range cases {
+		rec := httptest.NewRecorder()
+		newTestHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != code {
+			t.Errorf("%s: status %d is not valid", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	newTestHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/resize:w=10/10x10.png", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error(errors.New("method should not be allowed"))
+	}
+}
+
+func TestParseOps(t *testing.T) {
+	p, err := ParseOps("resize:width=300:h=200:a=bottom-right:ns=1,optimize:ms=1000:mq=40")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 2 {
+		t.Fatal(errors.New("step count is not valid"))
+	}
+
+	if p.Steps[0].Width != 300 || p.Steps[0].Height != 200 || p.Steps[0].Anchor != himage.BottomRight || !p.Steps[0].NoSharpen {
+		t.Error(errors.New("resize step is not valid"))
+	}
+
+	if p.Steps[1].MaxSize != 1000 || p.Steps[1].MinQuality != 40 {
+		t.Error(errors.New("optimize step is not valid"))
+	}
+
+	if _, err := ParseOps("resize:w"); err == nil {
+		t.Error(errors.New("invalid parameter should be rejected"))
+	}
+}
+
+func TestHandlerNegotiate(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/resize:w=100/850x566.png", nil)
+	req.Header.Set("Accept", "image/jpeg,image/*;q=0.8")
+	newTestHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	if rec.Header().Get("Content-Type") != "image/jpeg" || rec.Header().Get("Vary") != "Accept" {
+		t.Error(errors.New("negotiated headers are not valid"))
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/resize:w=100/850x566.png", nil)
+	req.Header.Set("Accept", "image/png")
+	newTestHandler().ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Type") != "image/png" {
+		t.Error(errors.New("negotiated content type is not valid"))
+	}
+}
+
+func TestHandlerNegotiateAnimated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/resize:w=50/100x80.gif", nil)
+	req.Header.Set("Accept", "image/png,image/*;q=0.8")
+	newTestHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	if rec.Header().Get("Content-Type") != "image/gif" {
+		t.Fatal(errors.New("animated content type is not valid"))
+	}
+
+	g, err := gif.DecodeAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 {
+		t.Error(errors.New("animated frames are not valid"))
+	}
+}
+
+func TestHandlerPixelLimit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resize:w=100000:h=100000/10x10.png", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Error(errors.New("ops over the pixel limit should be rejected"))
+	}
+
+	h := newTestHandler()
+	h.MaxPixels = 100 * 100
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resize:w=200/640x426.jpeg", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Error(errors.New("handler pixel limit is not valid"))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resize:w=50/640x426.jpeg", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Error(errors.New("source over the handler pixel limit is not valid"))
+	}
+}
@@ -0,0 +1,111 @@
+package himagehttp
+
+import (
+	"fmt"
+	"github.com/streetbyters/himage"
+	"strconv"
+	"strings"
+)
+
+// ParseOps parses a URL operation string into a validated pipeline.
+// Steps are separated by "," and parameters by ":", e.g.
+//
+//	resize:w=300:h=200:a=top,convert:f=png:q=80
+//
+// Short (w, h, r, a, f, q ..) and full parameter names are both accepted.
+func ParseOps(ops string) (*himage.Pipeline, error) {
+	p := new(himage.Pipeline)
+
+	for index, s := range strings.Split(ops, ",") {
+		parts := strings.Split(s, ":")
+		step := himage.Step{Op: parts[0]}
+
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("ops step %d: invalid parameter %q", index, param)
+			}
+			if err := setParam(&step, kv[0], kv[1]); err != nil {
+				return nil, fmt.Errorf("ops step %d: %s", index, err)
+			}
+		}
+
+		p.Steps = append(p.Steps, step)
+	}
+
+	if err := p.Valid(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// setParam ..
+func setParam(step *himage.Step, key string, value string) error {
+	var err error
+
+	switch key {
+	case "w", "width":
+		step.Width, err = strconv.Atoi(value)
+	case "h", "height":
+		step.Height, err = strconv.Atoi(value)
+	case "r", "ratio":
+		step.Ratio, err = strconv.Atoi(value)
+	case "a", "anchor":
+		err = step.Anchor.UnmarshalText([]byte(value))
+	case "wo", "width_oriented":
+		step.WidthOriented, err = strconv.ParseBool(value)
+	case "ho", "height_oriented":
+		step.HeightOriented, err = strconv.ParseBool(value)
+	case "max", "maximize":
+		step.Maximize, err = strconv.ParseBool(value)
+	case "min", "minimize":
+		step.Minimize, err = strconv.ParseBool(value)
+	case "f", "format":
+		step.Format = value
+	case "q", "quality":
+		step.Quality, err = strconv.Atoi(value)
+	case "ms", "max_size":
+		step.MaxSize, err = strconv.ParseInt(value, 10, 64)
+	case "mq", "min_quality":
+		step.MinQuality, err = strconv.Atoi(value)
+	case "ssim", "min_ssim":
+		step.MinSSIM, err = strconv.ParseFloat(value, 64)
+	case "deg", "angle":
+		step.Angle, err = strconv.ParseFloat(value, 64)
+	case "fr", "frame":
+		step.Frame, err = strconv.Atoi(value)
+	case "p", "percentage":
+		step.Percentage, err = strconv.ParseFloat(value, 64)
+	case "g", "gamma":
+		step.Gamma, err = strconv.ParseFloat(value, 64)
+	case "s", "sigma":
+		step.Sigma, err = strconv.ParseFloat(value, 64)
+	case "am", "amount":
+		step.Amount, err = strconv.ParseFloat(value, 64)
+	case "t", "threshold":
+		step.Threshold, err = strconv.ParseFloat(value, 64)
+	case "ns", "no_sharpen":
+		step.NoSharpen, err = strconv.ParseBool(value)
+	case "top":
+		step.Top, err = strconv.Atoi(value)
+	case "right":
+		step.Right, err = strconv.Atoi(value)
+	case "bottom":
+		step.Bottom, err = strconv.Atoi(value)
+	case "left":
+		step.Left, err = strconv.Atoi(value)
+	case "c", "color":
+		step.Color = value
+	case "rad", "radius":
+		step.Radius, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown parameter %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", key, value)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package himagehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer, err := NewSigner([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := signer.Sign("resize:w=100", "a/b.png", time.Time{})
+	if err := signer.Verify(signature, "resize:w=100", "a/b.png"); err != nil {
+		t.Error(err)
+	}
+
+	if err := signer.Verify(signature, "resize:w=2000", "a/b.png"); err != ErrSignatureInvalid {
+		t.Error(errors.New("changed ops should be rejected"))
+	}
+
+	if err := signer.Verify(signature, "resize:w=100", "a/c.png"); err != ErrSignatureInvalid {
+		t.Error(errors.New("changed path should be rejected"))
+	}
+
+	if err := signer.Verify("abc", "resize:w=100", "a/b.png"); err != ErrSignatureMalformed {
+		t.Error(errors.New("malformed signature should be rejected"))
+	}
+}
+
+func TestSignerKeyRotation(t *testing.T) {
+	old, _ := NewSigner([]byte("old"))
+	signature := old.Sign("resize:w=100", "b.png", time.Time{})
+
+	rotated, _ := NewSigner([]byte("new"), []byte("old"))
+	if err := rotated.Verify(signature, "resize:w=100", "b.png"); err != nil {
+		t.Error(err)
+	}
+
+	retired, _ := NewSigner([]byte("new"))
+	if err := retired.Verify(signature, "resize:w=100", "b.png"); err != ErrSignatureInvalid {
+		t.Error(errors.New("retired key should be rejected"))
+	}
+}
+
+func TestSignerExpiry(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	signer, _ := NewSigner([]byte("secret"))
+	signer.Now = func() time.Time {
+		return now
+	}
+
+	signature := signer.Sign("resize:w=100", "b.png", now.Add(time.Minute))
+	if err := signer.Verify(signature, "resize:w=100", "b.png"); err != nil {
+		t.Error(err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := signer.Verify(signature, "resize:w=100", "b.png"); err != ErrSignatureExpired {
+		t.Error(errors.New("expired signature should be rejected"))
+	}
+}
+
+func TestHandlerSigned(t *testing.T) {
+	signer, _ := NewSigner([]byte("secret"))
+	h := newTestHandler()
+	h.Signer = signer
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signer.SignURL("resize:w=20", "10x10.png", time.Time{}), nil))
+	if rec.Code != http.StatusOK {
+		t.Error(errors.New(rec.Body.String()))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unsigned/resize:w=20/10x10.png", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Error(errors.New("unsigned request should be forbidden"))
+	}
+
+	for _, path := range []string{"/resize:w=20/10x10.png", "/10x10.png"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), ErrSignatureMissing.Error()) {
+			t.Errorf("%s: missing signature should be forbidden", path)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package himagehttp
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidPath is returned for source paths escaping the storage root
+var ErrInvalidPath = errors.New("invalid source path")
+
+// Storage loads source images by their URL path
+type Storage interface {
+	Open(name string) (*os.File, error)
+}
+
+// Dir is a Storage serving files from a local directory
+type Dir string
+
+// Open ..
+func (d Dir) Open(name string) (*os.File, error) {
+	if strings.Contains(name, "\x00") || strings.Contains(name, "\\") {
+		return nil, ErrInvalidPath
+	}
+
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" {
+		return nil, ErrInvalidPath
+	}
+
+	f, err := os.Open(filepath.Join(string(d), filepath.FromSlash(cleaned)))
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+
+	return f, nil
+}
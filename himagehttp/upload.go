@@ -0,0 +1,267 @@
+package himagehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/streetbyters/himage"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Upload defaults
+const (
+	DefaultMaxFileSize    int64 = 20 << 20
+	DefaultMaxRequestSize int64 = 100 << 20
+	DefaultMaxFiles       int   = 20
+)
+
+// sniffSize bytes read from each part before accepting it
+const sniffSize = 3072
+
+// errNoDestination ..
+var errNoDestination = errors.New("upload destination is empty")
+
+// errRequestTooLarge ..
+var errRequestTooLarge = errors.New("request body too large")
+
+// DefaultAllowedTypes sniffed mime types accepted by Upload
+var DefaultAllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/tiff", "image/bmp"}
+
+// Upload is a multipart upload handler. File parts are streamed through
+// size limits and mime sniffing, processed by Pipeline and written to
+// Destination, and a JSON manifest is returned.
+type Upload struct {
+	Destination    string
+	Pipeline       *himage.Pipeline
+	MaxFileSize    int64
+	MaxRequestSize int64
+	MaxFiles       int
+	AllowedTypes   []string
+}
+
+// UploadResult is a manifest entry for a single file part
+type UploadResult struct {
+	Field    string `json:"field"`
+	Filename string `json:"filename"`
+	Name     string `json:"name,omitempty"`
+	Mime     string `json:"mime,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UploadManifest ..
+type UploadManifest struct {
+	Files []UploadResult `json:"files"`
+}
+
+// NewUpload ..
+func NewUpload(destination string, p *himage.Pipeline) *Upload {
+	return &Upload{
+		Destination:    destination,
+		Pipeline:       p,
+		MaxFileSize:    DefaultMaxFileSize,
+		MaxRequestSize: DefaultMaxRequestSize,
+		MaxFiles:       DefaultMaxFiles,
+		AllowedTypes:   DefaultAllowedTypes,
+	}
+}
+
+// ServeHTTP responds 200 when every file is stored, 207 on partial
+// failure and 422 when no file could be stored.
+func (u *Upload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if u.Destination == "" {
+		http.Error(w, errNoDestination.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var body *requestBody
+	if u.MaxRequestSize > 0 {
+		body = &requestBody{ReadCloser: r.Body, remaining: u.MaxRequestSize}
+		r.Body = body
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	manifest := UploadManifest{Files: make([]UploadResult, 0)}
+	failed := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.discard(manifest)
+			if body != nil && body.exceeded {
+				http.Error(w, errRequestTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		if u.MaxFiles > 0 && len(manifest.Files) >= u.MaxFiles {
+			part.Close()
+			u.discard(manifest)
+			http.Error(w, fmt.Sprintf("too many files, at most %d allowed", u.MaxFiles), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		result := u.store(part)
+		part.Close()
+		if result.Error != "" {
+			failed++
+		}
+		manifest.Files = append(manifest.Files, result)
+	}
+
+	if len(manifest.Files) == 0 {
+		http.Error(w, "no file parts", http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if failed == len(manifest.Files) {
+		status = http.StatusUnprocessableEntity
+	} else if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// discard removes the files stored before the request was rejected, the
+// client gets no manifest to find them
+func (u *Upload) discard(manifest UploadManifest) {
+	for _, result := range manifest.Files {
+		if result.Name != "" {
+			os.Remove(filepath.Join(u.Destination, result.Name))
+		}
+	}
+}
+
+// requestBody fails reads past the request size limit and records it
+type requestBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+// Read ..
+func (b *requestBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errRequestTooLarge
+	}
+
+	// one byte over the limit tells a body of exactly the limit apart
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errRequestTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// store streams a single part into a temp file, processes and stores it
+func (u *Upload) store(part *multipart.Part) UploadResult {
+	result := UploadResult{Field: part.FormName(), Filename: filepath.Base(part.FileName())}
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		result.Error = err.Error()
+		return result
+	}
+	head = head[:n]
+
+	mime := mimetype.Detect(head).String()
+	if !u.allowed(mime) {
+		result.Error = fmt.Sprintf("unsupported file type %s", mime)
+		return result
+	}
+
+	f, err := ioutil.TempFile("", "himage-upload")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	limit := u.MaxFileSize
+	if limit <= 0 {
+		limit = DefaultMaxFileSize
+	}
+	written, err := io.Copy(f, io.LimitReader(io.MultiReader(bytes.NewReader(head), part), limit+1))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if written > limit {
+		result.Error = fmt.Sprintf("file exceeds %d bytes", limit)
+		return result
+	}
+	f.Seek(0, 0)
+
+	i := himage.NewHimageWithFile(f).SetDestination(u.Destination).RemoveOrigin(true)
+	if u.Pipeline != nil && i.Error == nil {
+		u.Pipeline.Apply(i)
+	}
+
+	if _, err := i.Finish(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Name = filepath.Base(i.Output())
+	result.Mime = i.Detail.Mime
+	result.Width = i.Detail.Width
+	result.Height = i.Detail.Height
+	result.Size = i.Detail.Size
+
+	return result
+}
+
+// allowed ..
+func (u *Upload) allowed(mime string) bool {
+	types := u.AllowedTypes
+	if len(types) == 0 {
+		types = DefaultAllowedTypes
+	}
+
+	for _, t := range types {
+		if t == mime {
+			return true
+		}
+	}
+	return false
+}
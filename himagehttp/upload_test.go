@@ -0,0 +1,173 @@
+package himagehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/streetbyters/himage"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func multipartBody(t *testing.T, files map[string][]byte) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("title", "ignored")
+	for name, data := range files {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	writer.Close()
+
+	return body, writer.FormDataContentType()
+}
+
+func TestUpload(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	png, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "850x566.png"))
+	jpeg, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "640x426.jpeg"))
+	body, contentType := multipartBody(t, map[string][]byte{
+		"a.png":  png,
+		"b.jpeg": jpeg,
+	})
+
+	upload := NewUpload(dst, &himage.Pipeline{Steps: []himage.Step{
+		{Op: "resize", Width: 100, Height: 100, Anchor: himage.Top},
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	upload.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	var manifest UploadManifest
+	if err := json.NewDecoder(rec.Body).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Files) != 2 {
+		t.Fatal(errors.New("manifest is not valid"))
+	}
+
+	for _, f := range manifest.Files {
+		if f.Error != "" || f.Width != 100 || f.Height != 100 {
+			t.Error(errors.New("manifest entry is not valid"))
+		}
+
+		if _, err := os.Stat(filepath.Join(dst, f.Name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestUploadPartialFailure(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	png, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "10x10.png"))
+	large, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "1920x1280.jpeg"))
+	body, contentType := multipartBody(t, map[string][]byte{
+		"a.png":  png,
+		"b.txt":  []byte("not an image"),
+		"c.jpeg": large,
+	})
+
+	upload := NewUpload(dst, nil)
+	upload.MaxFileSize = 64 * 1024
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	upload.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatal(errors.New(rec.Body.String()))
+	}
+
+	var manifest UploadManifest
+	json.NewDecoder(rec.Body).Decode(&manifest)
+
+	failed := 0
+	for _, f := range manifest.Files {
+		if f.Error != "" {
+			failed++
+		}
+	}
+
+	if len(manifest.Files) != 3 || failed != 2 {
+		t.Error(errors.New("manifest is not valid"))
+	}
+}
+
+func TestUploadRequestTooLarge(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	large, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "1920x1280.jpeg"))
+	body, contentType := multipartBody(t, map[string][]byte{
+		"a.jpeg": large,
+		"b.jpeg": large,
+	})
+
+	upload := NewUpload(dst, nil)
+	upload.MaxRequestSize = int64(len(large))
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	upload.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Error(errors.New("status is not valid"))
+	}
+}
+
+func TestUploadRequestTooLargeDiscards(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	small, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "10x10.png"))
+	large, _ := ioutil.ReadFile(filepath.Join("..", "test-files", "1920x1280.jpeg"))
+
+	// parts are written in order so the small file is stored first
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for k, data := range [][]byte{small, large} {
+		part, err := writer.CreateFormFile("file", []string{"a.png", "b.jpeg"}[k])
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	writer.Close()
+
+	upload := NewUpload(dst, nil)
+	upload.MaxRequestSize = int64(len(small) + len(large)/2)
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	upload.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(errors.New("status is not valid: " + rec.Body.String()))
+	}
+
+	if files, _ := ioutil.ReadDir(dst); len(files) != 0 {
+		t.Error(errors.New("stored files should be discarded"))
+	}
+}
@@ -0,0 +1,134 @@
+// Package himagetest compares processed images with golden files.
+//
+// Goldens are PNG files compared by decoded pixels. Run the tests with
+// -himagetest.update to write the current results as the new goldens.
+package himagetest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/streetbyters/himage"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files with the current results, the flag is
+// prefixed so it does not clash with an -update flag of the importing tests
+var update = flag.Bool("himagetest.update", false, "update golden image files")
+
+// Tolerance is the accepted difference to a golden image, the zero value
+// requires identical pixels
+type Tolerance struct {
+	// MinSSIM is the lowest accepted structural similarity, zero skips it
+	MinSSIM float64
+	// PixelThreshold is the channel difference under which pixels are equal
+	PixelThreshold int
+	// MaxDiffPixels is the number of pixels allowed to differ, zero with
+	// MinSSIM set does not count them
+	MaxDiffPixels int
+}
+
+// AssertGolden compares got, an image.Image or a *himage.Himage, with the
+// golden PNG at goldenPath. On failure the actual and a diff image are
+// written next to the golden as <name>.actual.png and <name>.diff.png.
+func AssertGolden(t testing.TB, got interface{}, goldenPath string, tolerance Tolerance) {
+	t.Helper()
+
+	actual, err := decode(got)
+	if err != nil {
+		t.Fatalf("himagetest: %s", err)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), os.ModePerm); err != nil {
+			t.Fatalf("himagetest: %s", err)
+		}
+		if err := imaging.Save(actual, goldenPath); err != nil {
+			t.Fatalf("himagetest: %s", err)
+		}
+		t.Logf("himagetest: updated %s", goldenPath)
+		return
+	}
+
+	golden, err := imaging.Open(goldenPath)
+	if os.IsNotExist(err) {
+		t.Fatalf("himagetest: golden %s does not exist, run the test with -himagetest.update", goldenPath)
+	} else if err != nil {
+		t.Fatalf("himagetest: %s", err)
+	}
+
+	if failure := compare(golden, actual, tolerance); failure != "" {
+		t.Errorf("himagetest: %s does not match: %s%s", goldenPath, failure, writeFailure(goldenPath, golden, actual))
+	}
+}
+
+// compare returns why actual is not within tolerance of golden, or ""
+func compare(golden, actual image.Image, tolerance Tolerance) string {
+	if golden.Bounds().Size() != actual.Bounds().Size() {
+		return fmt.Sprintf("size %v, want %v", actual.Bounds().Size(), golden.Bounds().Size())
+	}
+
+	// with only MinSSIM the differing pixels are not counted
+	if tolerance.MaxDiffPixels > 0 || tolerance.MinSSIM == 0 {
+		_, count, err := himage.DiffImages(golden, actual, tolerance.PixelThreshold)
+		if err != nil {
+			return err.Error()
+		}
+		if count > tolerance.MaxDiffPixels {
+			return fmt.Sprintf("%d pixel(s) differ, want at most %d", count, tolerance.MaxDiffPixels)
+		}
+	}
+
+	if tolerance.MinSSIM > 0 {
+		m, err := himage.CompareImages(golden, actual)
+		if err != nil {
+			return err.Error()
+		}
+		if m.SSIM < tolerance.MinSSIM {
+			return fmt.Sprintf("ssim %.4f, want at least %.4f", m.SSIM, tolerance.MinSSIM)
+		}
+	}
+
+	return ""
+}
+
+// writeFailure writes the actual and diff images and describes them
+func writeFailure(goldenPath string, golden, actual image.Image) string {
+	base := strings.TrimSuffix(goldenPath, filepath.Ext(goldenPath))
+	written := make([]string, 0, 2)
+
+	if err := imaging.Save(actual, base+".actual.png"); err == nil {
+		written = append(written, base+".actual.png")
+	}
+
+	if diff, _, err := himage.DiffImages(golden, actual, 0); err == nil {
+		if err := imaging.Save(diff, base+".diff.png"); err == nil {
+			written = append(written, base+".diff.png")
+		}
+	}
+
+	if len(written) == 0 {
+		return ""
+	}
+	return " (wrote " + strings.Join(written, ", ") + ")"
+}
+
+// decode ..
+func decode(got interface{}) (image.Image, error) {
+	switch v := got.(type) {
+	case image.Image:
+		return v, nil
+	case *himage.Himage:
+		if v == nil {
+			return nil, errors.New("image is nil")
+		}
+		return v.Image()
+	}
+
+	return nil, fmt.Errorf("unsupported image type %T", got)
+}
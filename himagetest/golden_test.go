@@ -0,0 +1,114 @@
+package himagetest
+
+import (
+	"errors"
+	"flag"
+	"github.com/disintegration/imaging"
+	"github.com/streetbyters/himage"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// consumerUpdate is the common -update flag of an importing test binary,
+// registering it must not clash with the himagetest flag
+var consumerUpdate = flag.Bool("update", false, "update consumer files")
+
+func TestAssertGolden(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "himagetest")
+	defer os.RemoveAll(dir)
+
+	golden := filepath.Join(dir, "resize.png")
+	hImage := himage.NewHimageWithPath(filepath.Join("..", "test-files", "10x10.png")).
+		Resize(himage.Resize{Width: 20, Height: 20})
+	defer hImage.Finish()
+
+	im, err := hImage.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imaging.Save(im, golden); err != nil {
+		t.Fatal(err)
+	}
+
+	AssertGolden(t, hImage, golden, Tolerance{})
+	AssertGolden(t, im, golden, Tolerance{MinSSIM: 0.99})
+}
+
+func TestCompare(t *testing.T) {
+	golden := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	actual := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	actual.SetNRGBA(5, 5, color.NRGBA{R: 10, A: 10})
+
+	if compare(golden, actual, Tolerance{}) == "" {
+		t.Error(errors.New("different pixel should fail"))
+	}
+
+	if compare(golden, actual, Tolerance{PixelThreshold: 10}) != "" {
+		t.Error(errors.New("pixel threshold is not valid"))
+	}
+
+	if compare(golden, actual, Tolerance{MaxDiffPixels: 1}) != "" {
+		t.Error(errors.New("max diff pixels is not valid"))
+	}
+
+	gradient, perturbed := image.NewNRGBA(image.Rect(0, 0, 64, 64)), image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: 255}
+			gradient.SetNRGBA(x, y, c)
+			if (x+y)%7 == 0 {
+				c.B += 3
+			}
+			perturbed.SetNRGBA(x, y, c)
+		}
+	}
+
+	if compare(gradient, perturbed, Tolerance{}) == "" {
+		t.Error(errors.New("perturbed pixels should fail without tolerance"))
+	}
+
+	if failure := compare(gradient, perturbed, Tolerance{MinSSIM: 0.98}); failure != "" {
+		t.Error(errors.New("ssim tolerance is not valid: " + failure))
+	}
+
+	if compare(gradient, perturbed, Tolerance{MinSSIM: 0.98, MaxDiffPixels: 1}) == "" {
+		t.Error(errors.New("max diff pixels with ssim should fail"))
+	}
+
+	if compare(golden, image.NewNRGBA(image.Rect(0, 0, 5, 5)), Tolerance{}) == "" {
+		t.Error(errors.New("different size should fail"))
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "himagetest")
+	defer os.RemoveAll(dir)
+
+	golden := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	actual := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	actual.SetNRGBA(1, 1, color.NRGBA{G: 255, A: 255})
+
+	writeFailure(filepath.Join(dir, "case.png"), golden, actual)
+
+	diff, err := imaging.Open(filepath.Join(dir, "case.diff.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := diff.At(1, 1).RGBA(); r != 0xffff {
+		t.Error(errors.New("diff image is not valid"))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "case.actual.png")); err != nil {
+		t.Error(errors.New("actual image is not written"))
+	}
+}
+
+func TestUpdateFlag(t *testing.T) {
+	if flag.Lookup("himagetest.update") == nil || *consumerUpdate {
+		t.Error(errors.New("update flags are not valid"))
+	}
+}
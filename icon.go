@@ -0,0 +1,314 @@
+package himage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IconSet describes the favicon and app icons generated by Himage.IconSet
+type IconSet struct {
+	// Anchor positions the square crop of non-square sources
+	Anchor Anchor
+	// ICOSizes are the resolutions embedded in favicon.ico, at most 256
+	ICOSizes []int
+	// AppleSizes are the apple touch icon resolutions
+	AppleSizes []int
+	// AndroidSizes are the manifest icon resolutions
+	AndroidSizes []int
+	// Path is the URL prefix of the icons in site.webmanifest, defaults to "/"
+	Path            string
+	Name            string
+	ShortName       string
+	ThemeColor      string
+	BackgroundColor string
+	Display         string
+}
+
+// WebManifest ..
+type WebManifest struct {
+	Name            string            `json:"name,omitempty"`
+	ShortName       string            `json:"short_name,omitempty"`
+	Icons           []WebManifestIcon `json:"icons"`
+	ThemeColor      string            `json:"theme_color,omitempty"`
+	BackgroundColor string            `json:"background_color,omitempty"`
+	Display         string            `json:"display,omitempty"`
+}
+
+// WebManifestIcon ..
+type WebManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// NewIconSet returns the common favicon, apple touch and android icon sizes
+func NewIconSet(name string) IconSet {
+	return IconSet{
+		ICOSizes:     []int{16, 32, 48, 64},
+		AppleSizes:   []int{180},
+		AndroidSizes: []int{192, 512},
+		Path:         "/",
+		Name:         name,
+		ShortName:    name,
+		Display:      "standalone",
+	}
+}
+
+// Valid ..
+func (s IconSet) Valid() error {
+	if len(s.ICOSizes)+len(s.AppleSizes)+len(s.AndroidSizes) == 0 {
+		return errors.New("icon set has no sizes")
+	}
+
+	for _, size := range s.ICOSizes {
+		if size <= 0 || size > 256 {
+			return fmt.Errorf("ico size %d must be between 1 and 256", size)
+		}
+	}
+
+	for _, size := range append(append([]int{}, s.AppleSizes...), s.AndroidSizes...) {
+		if size <= 0 || size*size > MAX_PIXELS {
+			return fmt.Errorf("invalid icon size %d", size)
+		}
+	}
+
+	return nil
+}
+
+// AppleIconName is apple-touch-icon.png for 180 and carries the size otherwise
+func AppleIconName(size int) string {
+	if size == 180 {
+		return "apple-touch-icon.png"
+	}
+	return fmt.Sprintf("apple-touch-icon-%dx%d.png", size, size)
+}
+
+// AndroidIconName ..
+func AndroidIconName(size int) string {
+	return fmt.Sprintf("android-chrome-%dx%d.png", size, size)
+}
+
+// Manifest returns the site.webmanifest of the android icons
+func (s IconSet) Manifest() WebManifest {
+	prefix := s.Path
+	if prefix == "" {
+		prefix = "/"
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	m := WebManifest{
+		Name:            s.Name,
+		ShortName:       s.ShortName,
+		Icons:           make([]WebManifestIcon, 0, len(s.AndroidSizes)),
+		ThemeColor:      s.ThemeColor,
+		BackgroundColor: s.BackgroundColor,
+		Display:         s.Display,
+	}
+	for _, size := range s.AndroidSizes {
+		m.Icons = append(m.Icons, WebManifestIcon{
+			Src:   prefix + AndroidIconName(size),
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Type:  "image/png",
+		})
+	}
+
+	return m
+}
+
+// IconSet writes favicon.ico, the apple touch icons, the android icons and
+// site.webmanifest into the destination from a square crop of the image.
+// The working image is left unchanged, written paths are listed by Icons.
+func (i *Himage) IconSet(spec IconSet) *Himage {
+	if err := spec.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	if i.dst == "" {
+		i.Error = errors.New("icon set needs a destination")
+		return i
+	}
+
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Error != nil {
+		return i
+	}
+
+	largest := 0
+	for _, sizes := range [][]int{spec.ICOSizes, spec.AppleSizes, spec.AndroidSizes} {
+		for _, size := range sizes {
+			if size > largest {
+				largest = size
+			}
+		}
+	}
+
+	src, err := i.iconSource(largest)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	side := src.Bounds().Dx()
+	if src.Bounds().Dy() < side {
+		side = src.Bounds().Dy()
+	}
+	square := imaging.CropAnchor(src, side, side, imaging.Anchor(spec.Anchor))
+
+	if err := os.MkdirAll(i.dst, os.ModePerm); err != nil {
+		i.Error = err
+		return i
+	}
+
+	icons := make([]image.Image, 0, len(spec.ICOSizes))
+	for _, size := range spec.ICOSizes {
+		icons = append(icons, imaging.Resize(square, size, size, imaging.Lanczos))
+	}
+
+	if len(icons) > 0 {
+		buffer := new(bytes.Buffer)
+		if err := EncodeICO(buffer, icons); err != nil {
+			i.Error = err
+			return i
+		}
+		i.writeIcon("favicon.ico", buffer.Bytes())
+	}
+
+	for _, size := range spec.AppleSizes {
+		i.writeIconPNG(AppleIconName(size), square, size)
+	}
+
+	for _, size := range spec.AndroidSizes {
+		i.writeIconPNG(AndroidIconName(size), square, size)
+	}
+
+	if i.Error != nil || len(spec.AndroidSizes) == 0 {
+		return i
+	}
+
+	manifest, err := json.MarshalIndent(spec.Manifest(), "", "  ")
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	return i.writeIcon("site.webmanifest", append(manifest, '\n'))
+}
+
+// Icons returns the paths written by IconSet
+func (i *Himage) Icons() []string {
+	return i.icons
+}
+
+// iconSource decodes the image, SVG is rendered with its shorter side at size
+func (i *Himage) iconSource(size int) (image.Image, error) {
+	if i.Detail.Mime != "image/svg+xml" {
+		return imaging.Open(i.tempPath)
+	}
+
+	data, err := ioutil.ReadFile(i.tempPath)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := i.Detail.Width, i.Detail.Height
+	if w < h {
+		return renderSVG(data, size, size*h/w)
+	}
+	return renderSVG(data, size*w/h, size)
+}
+
+// writeIconPNG ..
+func (i *Himage) writeIconPNG(name string, square image.Image, size int) *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	buffer := new(bytes.Buffer)
+	im := imaging.Resize(square, size, size, imaging.Lanczos)
+	if err := imaging.Encode(buffer, im, imaging.PNG); err != nil {
+		i.Error = err
+		return i
+	}
+
+	return i.writeIcon(name, buffer.Bytes())
+}
+
+// writeIcon ..
+func (i *Himage) writeIcon(name string, data []byte) *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	output := filepath.Join(i.dst, name)
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		i.Error = err
+		return i
+	}
+	i.icons = append(i.icons, output)
+
+	return i
+}
+
+// EncodeICO writes the images as a PNG compressed ICO file, each image
+// must be at most 256x256
+func EncodeICO(w io.Writer, images []image.Image) error {
+	if len(images) == 0 || len(images) > 0xFFFF {
+		return errors.New("ico needs between 1 and 65535 images")
+	}
+
+	entries := make([][]byte, len(images))
+	for k, im := range images {
+		b := im.Bounds()
+		if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > 256 || b.Dy() > 256 {
+			return fmt.Errorf("ico image %dx%d must be at most 256x256", b.Dx(), b.Dy())
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := imaging.Encode(buffer, im, imaging.PNG); err != nil {
+			return err
+		}
+		entries[k] = buffer.Bytes()
+	}
+
+	header := new(bytes.Buffer)
+	binary.Write(header, binary.LittleEndian, [3]uint16{0, 1, uint16(len(images))})
+
+	offset := 6 + 16*len(images)
+	for k, im := range images {
+		b := im.Bounds()
+		binary.Write(header, binary.LittleEndian, struct {
+			Width, Height, Colors, Reserved uint8
+			Planes, BitCount                uint16
+			Size, Offset                    uint32
+		}{uint8(b.Dx()), uint8(b.Dy()), 0, 0, 1, 32, uint32(len(entries[k])), uint32(offset)})
+		offset += len(entries[k])
+	}
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if _, err := w.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package himage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageIconSet(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	spec := NewIconSet("Himage")
+	spec.ThemeColor = "#ffffff"
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+		SetDestination(dst).
+		IconSet(spec)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if len(hImage.Icons()) != 5 {
+		t.Error(errors.New("icon count is not valid"))
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dst, "favicon.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.LittleEndian.Uint16(data[2:]) != 1 || binary.LittleEndian.Uint16(data[4:]) != 4 || data[6+16*3] != 64 {
+		t.Error(errors.New("ico header is not valid"))
+	}
+
+	f, err := os.Open(filepath.Join(dst, "apple-touch-icon.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, err := png.DecodeConfig(f)
+	if err != nil || c.Width != 180 || c.Height != 180 {
+		t.Error(errors.New("apple touch icon is not valid"))
+	}
+
+	var manifest WebManifest
+	data, _ = ioutil.ReadFile(filepath.Join(dst, "site.webmanifest"))
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Icons) != 2 || manifest.Icons[1].Src != "/android-chrome-512x512.png" || manifest.ThemeColor != "#ffffff" {
+		t.Error(errors.New("manifest is not valid"))
+	}
+
+	if hImage.Detail.Width != 640 {
+		t.Error(errors.New("working image should not be changed"))
+	}
+}
+
+func TestHimageIconSetInvalid(t *testing.T) {
+	spec := NewIconSet("Himage")
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).IconSet(spec)
+	if hImage.Error == nil {
+		t.Error(errors.New("missing destination should fail"))
+	}
+
+	spec.ICOSizes = []int{512}
+	if spec.Valid() == nil {
+		t.Error(errors.New("ico size over 256 should fail"))
+	}
+}
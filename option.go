@@ -2,6 +2,11 @@ package himage
 
 import (
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
 )
 
 // Anchor is the anchor point for image alignment.
@@ -22,6 +27,79 @@ const (
 	BottomRight
 )
 
+// anchorNames ..
+var anchorNames = []string{
+	"center",
+	"top-left",
+	"top",
+	"top-right",
+	"left",
+	"right",
+	"bottom-left",
+	"bottom",
+	"bottom-right",
+}
+
+// String ..
+func (a Anchor) String() string {
+	if a < 0 || int(a) >= len(anchorNames) {
+		return fmt.Sprintf("Anchor(%d)", int(a))
+	}
+	return anchorNames[a]
+}
+
+// MarshalText ..
+func (a Anchor) MarshalText() ([]byte, error) {
+	if a < 0 || int(a) >= len(anchorNames) {
+		return nil, fmt.Errorf("invalid anchor %d", int(a))
+	}
+	return []byte(anchorNames[a]), nil
+}
+
+// UnmarshalText accepts anchor names like "center" or "top-left"
+func (a *Anchor) UnmarshalText(text []byte) error {
+	name := strings.Replace(strings.ToLower(string(text)), "_", "-", -1)
+	for index, n := range anchorNames {
+		if n == name || strings.Replace(n, "-", "", -1) == name {
+			*a = Anchor(index)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid anchor %q", string(text))
+}
+
+// AlphaPolicy decides how transparency added by an operation is saved
+// when the output format cannot store it
+type AlphaPolicy int
+
+const (
+	// AlphaConvert switches the output to PNG
+	AlphaConvert AlphaPolicy = iota
+	// AlphaError fails the operation
+	AlphaError
+)
+
+// Checksum selects the SHA-256 digests computed while streaming
+type Checksum int
+
+const (
+	// ChecksumSource hashes the source while it is moved, see Detail.SourceSHA256
+	ChecksumSource Checksum = 1 << iota
+	// ChecksumOutput hashes the result written by Finish, see Detail.OutputSHA256
+	ChecksumOutput
+)
+
+// Naming is the file naming strategy of Finish
+type Naming int
+
+const (
+	// NamingDefault uses SetName or a random UUID
+	NamingDefault Naming = iota
+	// NamingContent stores results at ab/cd/<sha256>.<ext> under the
+	// destination, identical results share a single file
+	NamingContent
+)
+
 // Resize ..
 type Resize struct {
 	Anchor         Anchor
@@ -32,13 +110,272 @@ type Resize struct {
 	HeightOriented bool
 	Maximize       bool
 	Minimize       bool
+	// Sharpen is applied after downscaling, zero uses DefaultSharpen
+	Sharpen Sharpen
+	// NoSharpen disables the sharpening after downscaling
+	NoSharpen bool
 }
 
+// DefaultSharpen is the unsharp mask applied after downscaling
+var DefaultSharpen = Sharpen{Sigma: 0.5, Amount: 0.5}
+
 // Valid ..
 func (r Resize) Valid() error {
 	if (r.Ratio > 0 && r.Width > 0) || (r.Ratio > 0 && r.Height > 0) {
 		return errors.New("both ratio and resolution cannot be specified at the same time")
 	}
 
+	if r.Width <= 0 && r.Height <= 0 && r.Ratio <= 0 {
+		return errors.New("resize width, height or ratio is required")
+	}
+
+	if r.Sharpen != (Sharpen{}) {
+		if r.NoSharpen {
+			return errors.New("sharpen cannot be specified with no sharpen")
+		}
+		return r.Sharpen.Valid()
+	}
+
+	return nil
+}
+
+// sharpen returns the mask applied after downscaling, false when disabled
+func (r Resize) sharpen() (Sharpen, bool) {
+	if r.NoSharpen {
+		return Sharpen{}, false
+	}
+	if r.Sharpen == (Sharpen{}) {
+		return DefaultSharpen, true
+	}
+	return r.Sharpen, true
+}
+
+// dimensions returns the requested width and height on a srcW x srcH
+// source. A Ratio shrinks the source sides by 1/Ratio, or grows them with
+// Maximize; WidthOriented or HeightOriented scales that side only and
+// keeps the aspect ratio.
+func (r Resize) dimensions(srcW, srcH int) (int, int) {
+	if r.Ratio <= 0 {
+		return r.Width, r.Height
+	}
+
+	scale := func(v int) int {
+		if r.Maximize {
+			v += v / r.Ratio
+		} else {
+			v -= v / r.Ratio
+		}
+		if v < 1 {
+			return 1
+		}
+		return v
+	}
+
+	switch {
+	case r.WidthOriented:
+		return scale(srcW), 0
+	case r.HeightOriented:
+		return 0, scale(srcH)
+	}
+	return scale(srcW), scale(srcH)
+}
+
+// size returns the output resolution for a srcW x srcH source, a missing
+// side keeps the aspect ratio like imaging.Resize
+func (r Resize) size(srcW, srcH float64) (float64, float64) {
+	w, h := r.dimensions(int(srcW), int(srcH))
+	width, height := float64(w), float64(h)
+	if r.Anchor > 0 || width <= 0 && height <= 0 || srcW <= 0 || srcH <= 0 {
+		return width, height
+	}
+
+	if width <= 0 {
+		width = math.Max(1, math.Floor(height*srcW/srcH+0.5))
+	} else if height <= 0 {
+		height = math.Max(1, math.Floor(width*srcH/srcW+0.5))
+	}
+	return width, height
+}
+
+// cover returns the scaled source resolution an anchored fill draws
+// before cropping, the output resolution otherwise
+func (r Resize) cover(srcW, srcH float64) (float64, float64) {
+	width, height := r.size(srcW, srcH)
+	if r.Anchor == 0 || width <= 0 || height <= 0 || srcW <= 0 || srcH <= 0 {
+		return width, height
+	}
+
+	scale := math.Max(width/srcW, height/srcH)
+	return math.Max(width, math.Ceil(srcW*scale)), math.Max(height, math.Ceil(srcH*scale))
+}
+
+// Crop ..
+type Crop struct {
+	Anchor Anchor
+	Width  int
+	Height int
+}
+
+// Valid ..
+func (c Crop) Valid() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return errors.New("crop width and height must be greater than zero")
+	}
+
+	return nil
+}
+
+// Sharpen is an unsharp mask
+type Sharpen struct {
+	// Sigma is the blur radius of the mask
+	Sigma float64
+	// Amount scales the added detail, zero means 1
+	Amount float64
+	// Threshold is the smallest channel difference in 0-255 that is sharpened
+	Threshold float64
+}
+
+// Valid ..
+func (s Sharpen) Valid() error {
+	if s.Sigma <= 0 {
+		return errors.New("sharpen sigma must be greater than zero")
+	}
+
+	if s.Amount < 0 || s.Amount > 10 {
+		return errors.New("sharpen amount must be between 0 and 10")
+	}
+
+	if s.Threshold < 0 || s.Threshold > 255 {
+		return errors.New("sharpen threshold must be between 0 and 255")
+	}
+
+	return nil
+}
+
+// Optimize ..
+type Optimize struct {
+	// MaxSize is the maximum encoded size in bytes, zero means no limit
+	MaxSize int64
+	// MinQuality is the lowest JPEG quality allowed while fitting MaxSize, zero
+	// allows any quality
+	MinQuality int
+	// MinSSIM is the lowest structural similarity to the unoptimized image
+	// allowed for JPEG, zero disables the floor
+	MinSSIM float64
+}
+
+// Valid ..
+func (o Optimize) Valid() error {
+	if o.MaxSize < 0 {
+		return errors.New("max size cannot be negative")
+	}
+
+	if o.MinQuality < 0 || o.MinQuality > 100 {
+		return errors.New("min quality must be between 0 and 100")
+	}
+
+	if o.MinSSIM < 0 || o.MinSSIM > 1 {
+		return errors.New("min ssim must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// Overlay ..
+type Overlay struct {
+	// Anchor positions the overlay, ignored when tiled
+	Anchor Anchor
+	// Margin is the distance in pixels to the anchored edges, or the gap between tiles
+	Margin int
+	// Opacity is between 0 and 1, zero is invisible and nil fully opaque
+	Opacity *float64
+	// Scale is the overlay width relative to the base width, zero keeps its size
+	Scale float64
+	// Tile repeats the overlay over the whole image
+	Tile bool
+}
+
+// Valid ..
+func (o Overlay) Valid() error {
+	if o.Margin < 0 {
+		return errors.New("overlay margin cannot be negative")
+	}
+
+	if o.Opacity != nil && (*o.Opacity < 0 || *o.Opacity > 1) {
+		return errors.New("overlay opacity must be between 0 and 1")
+	}
+
+	if o.Scale < 0 || o.Scale > 1 {
+		return errors.New("overlay scale must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// Text ..
+type Text struct {
+	// Font is parsed from TTF or OTF bytes with ParseFont
+	Font *Font
+	// Size is the font size relative to the base width
+	Size float64
+	// Color of the text, white when nil
+	Color color.Color
+	// StrokeColor outlines the text when StrokeWidth is set
+	StrokeColor color.Color
+	StrokeWidth int
+	// ShadowColor draws a shadow moved by ShadowOffset
+	ShadowColor  color.Color
+	ShadowOffset image.Point
+	// Angle rotates the text counter-clockwise in degrees
+	Angle  float64
+	Anchor Anchor
+	Margin int
+}
+
+// Valid ..
+func (t Text) Valid() error {
+	if t.Font == nil {
+		return errors.New("text font is nil")
+	}
+
+	if t.Size <= 0 || t.Size > 1 {
+		return errors.New("text size must be between 0 and 1")
+	}
+
+	if t.StrokeWidth < 0 || t.Margin < 0 {
+		return errors.New("text stroke width and margin cannot be negative")
+	}
+
+	return nil
+}
+
+// Placeholder ..
+type Placeholder struct {
+	// Width of the placeholder, defaults to 16
+	Width int
+	// Sigma of the placeholder blur, defaults to 1
+	Sigma float64
+	// NoBlur keeps the downscaled placeholder unblurred
+	NoBlur bool
+	// Format is jpeg (default), png or gif
+	Format string
+	// Quality of a JPEG placeholder, defaults to 50
+	Quality int
+}
+
+// Valid ..
+func (p Placeholder) Valid() error {
+	if p.Width < 0 || p.Width > 128 {
+		return errors.New("placeholder width must be between 1 and 128, 0 means the default")
+	}
+
+	if p.Sigma < 0 {
+		return errors.New("placeholder sigma cannot be negative")
+	}
+
+	if p.Quality < 0 || p.Quality > 100 {
+		return errors.New("placeholder quality must be between 1 and 100, 0 means the default")
+	}
+
 	return nil
 }
@@ -0,0 +1,97 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Overlay composites img, an image.Image or a *Himage, over the image.
+// Every frame of an animated GIF is watermarked.
+func (i *Himage) Overlay(img interface{}, option Overlay) *Himage {
+	if err := option.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	mark, err := overlayImage(img)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		return overlay(src, mark, option)
+	})
+}
+
+// overlayImage ..
+func overlayImage(img interface{}) (image.Image, error) {
+	switch v := img.(type) {
+	case image.Image:
+		return v, nil
+	case *Himage:
+		if v == nil {
+			return nil, errors.New("overlay image is nil")
+		}
+		if v.Error != nil {
+			return nil, v.Error
+		}
+		return v.image()
+	}
+
+	return nil, fmt.Errorf("unsupported overlay type %T", img)
+}
+
+// overlay draws mark over a copy of src
+func overlay(src image.Image, mark image.Image, option Overlay) *image.NRGBA {
+	dst := imaging.Clone(src)
+	bounds := dst.Bounds()
+
+	if option.Scale > 0 {
+		width := int(math.Max(1, math.Round(option.Scale*float64(bounds.Dx()))))
+		mark = imaging.Resize(mark, width, 0, imaging.Lanczos)
+	}
+
+	opacity := 1.0
+	if option.Opacity != nil {
+		opacity = *option.Opacity
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(opacity * 255))})
+
+	mw, mh := mark.Bounds().Dx(), mark.Bounds().Dy()
+	if mw == 0 || mh == 0 {
+		return dst
+	}
+
+	if option.Tile {
+		for y := option.Margin; y < bounds.Dy(); y += mh + option.Margin {
+			for x := option.Margin; x < bounds.Dx(); x += mw + option.Margin {
+				draw.DrawMask(dst, image.Rect(x, y, x+mw, y+mh), mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+			}
+		}
+		return dst
+	}
+
+	x, y := (bounds.Dx()-mw)/2, (bounds.Dy()-mh)/2
+	switch option.Anchor {
+	case TopLeft, Left, BottomLeft:
+		x = option.Margin
+	case TopRight, Right, BottomRight:
+		x = bounds.Dx() - mw - option.Margin
+	}
+	switch option.Anchor {
+	case TopLeft, Top, TopRight:
+		y = option.Margin
+	case BottomLeft, Bottom, BottomRight:
+		y = bounds.Dy() - mh - option.Margin
+	}
+
+	draw.DrawMask(dst, image.Rect(x, y, x+mw, y+mh), mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst
+}
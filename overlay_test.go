@@ -0,0 +1,76 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageOverlay(t *testing.T) {
+	mark := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for k := 0; k < len(mark.Pix); k += 4 {
+		mark.Pix[k], mark.Pix[k+3] = 255, 255
+	}
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).
+		Overlay(mark, Overlay{Anchor: BottomRight, Margin: 5})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	im, err := hImage.image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := color.NRGBAModel.Convert(im.At(850-10, 566-10)).(color.NRGBA); c.R != 255 || c.G != 0 {
+		t.Error(errors.New("overlay position is not valid"))
+	}
+
+	half, invisible := 0.5, 0.0
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	out := overlay(src, mark, Overlay{Tile: true, Margin: 10, Opacity: &half})
+	if out.NRGBAAt(15, 15).A == 0 || out.NRGBAAt(25, 15).A != 0 || out.NRGBAAt(35, 35).A == 0 {
+		t.Error(errors.New("tiled overlay is not valid"))
+	}
+
+	out = overlay(src, mark, Overlay{Scale: 0.5})
+	if out.NRGBAAt(25, 12).A == 0 || out.NRGBAAt(20, 12).A != 0 {
+		t.Error(errors.New("scaled overlay is not valid"))
+	}
+
+	if out = overlay(src, mark, Overlay{Opacity: &half}); out.NRGBAAt(50, 25).A != 128 {
+		t.Error(errors.New("overlay opacity is not valid"))
+	}
+
+	if out = overlay(src, mark, Overlay{Opacity: &invisible}); out.NRGBAAt(50, 25).A != 0 {
+		t.Error(errors.New("invisible overlay is not valid"))
+	}
+}
+
+func TestHimageOverlayHimage(t *testing.T) {
+	half, over := 0.5, 2.0
+	mark := NewHimageWithPath(filepath.Join("test-files", "10x10.png"))
+	hImage := NewHimageWithPath(filepath.Join("test-files", "100x80.gif")).
+		Overlay(mark, Overlay{Anchor: TopLeft, Opacity: &half})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Frames != 3 {
+		t.Error(errors.New("animated overlay is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "10x10.png")).Overlay("mark", Overlay{})
+	if hImage.Error == nil {
+		t.Error(errors.New("unsupported overlay should fail"))
+	}
+
+	if (Overlay{Opacity: &over}).Valid() == nil {
+		t.Error(errors.New("invalid opacity should fail"))
+	}
+}
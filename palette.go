@@ -0,0 +1,180 @@
+package himage
+
+import (
+	"errors"
+	"github.com/disintegration/imaging"
+	"image/color"
+	"sort"
+)
+
+// Tone is the light or dark classification of an image
+type Tone int
+
+// Tones, ToneUnknown until Palette is called.
+const (
+	ToneUnknown Tone = iota
+	ToneLight
+	ToneDark
+)
+
+// String ..
+func (t Tone) String() string {
+	switch t {
+	case ToneLight:
+		return "light"
+	case ToneDark:
+		return "dark"
+	}
+	return "unknown"
+}
+
+// PaletteColor is a representative color and the share of pixels it stands for
+type PaletteColor struct {
+	Color color.NRGBA
+	// Population is between 0 and 1
+	Population float64
+}
+
+// Hex returns the color as #rrggbb
+func (p PaletteColor) Hex() string {
+	return hexColor(p.Color)
+}
+
+// Palette fills Detail.Palette with up to k colors by median cut, most
+// populated first, and Detail.AverageColor and Detail.Tone from a
+// downsampled copy. Mostly transparent pixels are skipped.
+func (i *Himage) Palette(k int) *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	if k < 1 || k > 256 {
+		i.Error = errors.New("palette size must be between 1 and 256")
+		return i
+	}
+
+	src, err := i.image()
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	im := imaging.Fit(src, INSPECT_SIZE, INSPECT_SIZE, imaging.Box)
+	pixels := make([][3]uint8, 0, len(im.Pix)/4)
+	for p := 0; p < len(im.Pix); p += 4 {
+		if im.Pix[p+3] >= 128 {
+			pixels = append(pixels, [3]uint8{im.Pix[p], im.Pix[p+1], im.Pix[p+2]})
+		}
+	}
+
+	i.Detail.Palette = medianCut(pixels, k)
+	i.Detail.AverageColor = averageColor(pixels)
+	i.Detail.Tone = tone(i.Detail.AverageColor)
+	if len(pixels) == 0 {
+		i.Detail.Tone = ToneUnknown
+	}
+
+	return i
+}
+
+// medianCut splits the most populated box along its widest channel until
+// there are k boxes
+func medianCut(pixels [][3]uint8, k int) []PaletteColor {
+	palette := make([]PaletteColor, 0, k)
+	if len(pixels) == 0 {
+		return palette
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < k {
+		index, channel := -1, 0
+		for b, box := range boxes {
+			c, spread := widestChannel(box)
+			if spread == 0 || len(box) < 2 {
+				continue
+			}
+			if index < 0 || len(box) > len(boxes[index]) {
+				index, channel = b, c
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		sort.Slice(box, func(x, y int) bool { return box[x][channel] < box[y][channel] })
+		// move the cut off runs of the same value so equal colors share a box
+		median := len(box) / 2
+		for median < len(box) && box[median][channel] == box[median-1][channel] {
+			median++
+		}
+		if median == len(box) {
+			for median = len(box) / 2; box[median][channel] == box[median-1][channel]; median-- {
+			}
+		}
+		boxes[index] = box[:median]
+		boxes = append(boxes, box[median:])
+	}
+
+	for _, box := range boxes {
+		palette = append(palette, PaletteColor{
+			Color:      averageColor(box),
+			Population: float64(len(box)) / float64(len(pixels)),
+		})
+	}
+
+	sort.SliceStable(palette, func(x, y int) bool { return palette[x].Population > palette[y].Population })
+
+	return palette
+}
+
+// widestChannel returns the channel with the largest range and the range
+func widestChannel(box [][3]uint8) (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	hi := [3]uint8{}
+	for _, p := range box {
+		for c := 0; c < 3; c++ {
+			if p[c] < lo[c] {
+				lo[c] = p[c]
+			}
+			if p[c] > hi[c] {
+				hi[c] = p[c]
+			}
+		}
+	}
+
+	channel, spread := 0, -1
+	for c := 0; c < 3; c++ {
+		if int(hi[c])-int(lo[c]) > spread {
+			channel, spread = c, int(hi[c])-int(lo[c])
+		}
+	}
+	return channel, spread
+}
+
+// averageColor ..
+func averageColor(pixels [][3]uint8) color.NRGBA {
+	if len(pixels) == 0 {
+		return color.NRGBA{}
+	}
+
+	var sum [3]int
+	for _, p := range pixels {
+		for c := 0; c < 3; c++ {
+			sum[c] += int(p[c])
+		}
+	}
+
+	n := len(pixels)
+	return color.NRGBA{R: uint8((sum[0] + n/2) / n), G: uint8((sum[1] + n/2) / n), B: uint8((sum[2] + n/2) / n), A: 255}
+}
+
+// tone classifies a color by its relative luminance, light colors need
+// dark foregrounds for contrast
+func tone(c color.NRGBA) Tone {
+	l := 0.2126*sRGBToLinear(c.R) + 0.7152*sRGBToLinear(c.G) + 0.0722*sRGBToLinear(c.B)
+	if l > 0.179 {
+		return ToneLight
+	}
+	return ToneDark
+}
@@ -0,0 +1,92 @@
+package himage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMedianCut(t *testing.T) {
+	pixels := make([][3]uint8, 0)
+	for k := 0; k < 75; k++ {
+		pixels = append(pixels, [3]uint8{250, 250, 250})
+	}
+	for k := 0; k < 25; k++ {
+		pixels = append(pixels, [3]uint8{10, 20, 200})
+	}
+
+	palette := medianCut(pixels, 4)
+	if len(palette) < 2 || palette[0].Hex() != "#fafafa" {
+		t.Error(errors.New("palette is not valid"))
+	}
+
+	total := 0.0
+	for _, c := range palette {
+		total += c.Population
+	}
+	if total < 0.999 || total > 1.001 {
+		t.Error(errors.New("palette population is not valid"))
+	}
+
+	if len(medianCut(pixels[:1], 4)) != 1 {
+		t.Error(errors.New("single color palette is not valid"))
+	}
+}
+
+func TestHimagePalette(t *testing.T) {
+	f := writeTestImage(t, func(im *image.NRGBA) {
+		draw.Draw(im, im.Bounds(), image.NewUniform(color.NRGBA{R: 20, G: 20, B: 30, A: 255}), image.Point{}, draw.Src)
+		draw.Draw(im, image.Rect(0, 0, 10, 40), image.NewUniform(color.NRGBA{R: 240, G: 200, B: 10, A: 255}), image.Point{}, draw.Src)
+	})
+
+	hImage := NewHimageWithPath(f).Palette(3)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if len(hImage.Detail.Palette) != 2 || hImage.Detail.Palette[0].Hex() != "#14141e" || hImage.Detail.Palette[0].Population != 0.75 {
+		t.Error(errors.New("palette is not valid"))
+	}
+
+	if hImage.Detail.Tone != ToneDark || hImage.Detail.AverageColor.R != 75 {
+		t.Error(errors.New("average color or tone is not valid"))
+	}
+
+	if tone(color.NRGBA{R: 255, G: 255, B: 255, A: 255}) != ToneLight {
+		t.Error(errors.New("light tone is not valid"))
+	}
+
+	if NewHimageWithPath(f).Palette(0).Error == nil {
+		t.Error(errors.New("invalid palette size should fail"))
+	}
+
+	missing := NewHimageWithPath(filepath.Join("test-files", "missing.png"))
+	loadErr := missing.Error
+	if loadErr == nil || missing.Palette(0).Error != loadErr {
+		t.Error(errors.New("load error should be kept"))
+	}
+}
+
+// writeTestImage writes a 40x40 PNG drawn by fn and returns its path
+func writeTestImage(t *testing.T, fn func(im *image.NRGBA)) string {
+	im := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	fn(im)
+
+	f, err := ioutil.TempFile("", "himage*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if err := png.Encode(f, im); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
@@ -0,0 +1,597 @@
+package himage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Step is a single serializable pipeline operation. Resize parameters
+// mirror the Resize option, other fields apply to the named op only.
+type Step struct {
+	Op             string  `json:"op" yaml:"op"`
+	Anchor         Anchor  `json:"anchor,omitempty" yaml:"anchor,omitempty"`
+	Ratio          int     `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+	Width          int     `json:"width,omitempty" yaml:"width,omitempty"`
+	Height         int     `json:"height,omitempty" yaml:"height,omitempty"`
+	WidthOriented  bool    `json:"width_oriented,omitempty" yaml:"width_oriented,omitempty"`
+	HeightOriented bool    `json:"height_oriented,omitempty" yaml:"height_oriented,omitempty"`
+	Maximize       bool    `json:"maximize,omitempty" yaml:"maximize,omitempty"`
+	Minimize       bool    `json:"minimize,omitempty" yaml:"minimize,omitempty"`
+	NoSharpen      bool    `json:"no_sharpen,omitempty" yaml:"no_sharpen,omitempty"`
+	Format         string  `json:"format,omitempty" yaml:"format,omitempty"`
+	Quality        int     `json:"quality,omitempty" yaml:"quality,omitempty"`
+	MaxSize        int64   `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	MinQuality     int     `json:"min_quality,omitempty" yaml:"min_quality,omitempty"`
+	MinSSIM        float64 `json:"min_ssim,omitempty" yaml:"min_ssim,omitempty"`
+	Angle          float64 `json:"angle,omitempty" yaml:"angle,omitempty"`
+	Frame          int     `json:"frame,omitempty" yaml:"frame,omitempty"`
+	Percentage     float64 `json:"percentage,omitempty" yaml:"percentage,omitempty"`
+	Gamma          float64 `json:"gamma,omitempty" yaml:"gamma,omitempty"`
+	Sigma          float64 `json:"sigma,omitempty" yaml:"sigma,omitempty"`
+	Amount         float64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Threshold      float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Top            int     `json:"top,omitempty" yaml:"top,omitempty"`
+	Right          int     `json:"right,omitempty" yaml:"right,omitempty"`
+	Bottom         int     `json:"bottom,omitempty" yaml:"bottom,omitempty"`
+	Left           int     `json:"left,omitempty" yaml:"left,omitempty"`
+	Color          string  `json:"color,omitempty" yaml:"color,omitempty"`
+	Radius         int     `json:"radius,omitempty" yaml:"radius,omitempty"`
+}
+
+// Pipeline is an ordered list of steps applied to a Himage
+type Pipeline struct {
+	Steps []Step `json:"steps" yaml:"steps"`
+
+	// lines source line of each step and field, keyed by field path
+	lines map[string]int
+}
+
+// PipelineError locates an invalid pipeline field
+type PipelineError struct {
+	// Path of the invalid field like steps[1].width
+	Path string
+	// Line in the source document, zero when unknown
+	Line int
+	// Column in the source document, zero when unknown
+	Column int
+	Err    error
+}
+
+// Error ..
+func (e *PipelineError) Error() string {
+	location := e.Path
+	if location == "" && e.Line == 0 {
+		return fmt.Sprintf("pipeline: %s", e.Err)
+	}
+	if e.Line > 0 {
+		location = fmt.Sprintf("line %d:%d", e.Line, e.Column)
+		if e.Path != "" {
+			location = fmt.Sprintf("%s (%s)", e.Path, location)
+		}
+	}
+	return fmt.Sprintf("pipeline %s: %s", location, e.Err)
+}
+
+// Unwrap ..
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// pipelineOps allowed step ops and the fields they accept
+var pipelineOps = map[string][]string{
+	"resize":        {"anchor", "ratio", "width", "height", "width_oriented", "height_oriented", "maximize", "minimize", "sigma", "amount", "threshold", "no_sharpen"},
+	"crop":          {"anchor", "width", "height"},
+	"convert":       {"format", "quality"},
+	"optimize":      {"max_size", "min_quality", "min_ssim"},
+	"rotate":        {"angle"},
+	"poster":        {"frame"},
+	"brightness":    {"percentage"},
+	"contrast":      {"percentage"},
+	"gamma":         {"gamma"},
+	"saturation":    {"percentage"},
+	"hue":           {"angle"},
+	"grayscale":     {},
+	"sepia":         {},
+	"invert":        {},
+	"blur":          {"sigma"},
+	"sharpen":       {"sigma", "amount", "threshold"},
+	"pad":           {"top", "right", "bottom", "left", "color"},
+	"border":        {"width", "color"},
+	"round_corners": {"radius"},
+	"circle_mask":   {},
+	"analyze":       {},
+}
+
+// ParsePipelineJSON decodes and validates a JSON pipeline
+func ParsePipelineJSON(data []byte) (*Pipeline, error) {
+	p := new(Pipeline)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		e := &PipelineError{Err: err}
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			e.Line, e.Column = position(data, err.Offset)
+		case *json.UnmarshalTypeError:
+			e.Path = err.Field
+			e.Line, e.Column = position(data, err.Offset)
+		}
+		return nil, e
+	}
+
+	if err := p.Valid(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ParsePipelineYAML decodes and validates a YAML pipeline
+func ParsePipelineYAML(data []byte) (*Pipeline, error) {
+	p := new(Pipeline)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil {
+		return nil, &PipelineError{Err: err}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil {
+		p.lines = yamlLines(&root)
+	}
+
+	if err := p.Valid(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LoadPipeline reads a pipeline file, the format is chosen by extension
+func LoadPipeline(path string) (*Pipeline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParsePipelineYAML(data)
+	case ".json":
+		return ParsePipelineJSON(data)
+	}
+
+	return nil, fmt.Errorf("unsupported pipeline file %q", path)
+}
+
+// Valid checks every step up front and reports the first invalid field
+func (p *Pipeline) Valid() error {
+	if len(p.Steps) == 0 {
+		return p.fail("steps", errors.New("at least one step is required"))
+	}
+
+	for index, step := range p.Steps {
+		if err := p.validStep(index, step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validStep ..
+func (p *Pipeline) validStep(index int, step Step) error {
+	path := fmt.Sprintf("steps[%d]", index)
+
+	fields, ok := pipelineOps[step.Op]
+	if !ok {
+		return p.fail(path+".op", fmt.Errorf("unknown op %q", step.Op))
+	}
+
+	for _, field := range step.setFields() {
+		if !stringsContain(fields, field) {
+			return p.fail(path+"."+field, fmt.Errorf("not applicable to %s", step.Op))
+		}
+	}
+
+	switch step.Op {
+	case "resize":
+		if step.Width < 0 {
+			return p.fail(path+".width", errors.New("cannot be negative"))
+		}
+		if step.Height < 0 {
+			return p.fail(path+".height", errors.New("cannot be negative"))
+		}
+		if step.Ratio < 0 {
+			return p.fail(path+".ratio", errors.New("cannot be negative"))
+		}
+		if step.Width == 0 && step.Height == 0 && step.Ratio == 0 {
+			return p.fail(path, errors.New("width, height or ratio is required"))
+		}
+		if err := validPixels(float64(step.Width), float64(step.Height)); err != nil {
+			return p.fail(path+".width", err)
+		}
+		if step.Sigma != 0 || step.Amount != 0 || step.Threshold != 0 {
+			if step.NoSharpen {
+				return p.fail(path+".no_sharpen", errors.New("cannot be combined with sigma, amount or threshold"))
+			}
+			if err := p.validSharpen(path, step); err != nil {
+				return err
+			}
+		}
+		if err := step.resize().Valid(); err != nil {
+			return p.fail(path+".ratio", err)
+		}
+	case "crop":
+		if step.Width <= 0 {
+			return p.fail(path+".width", errors.New("must be greater than zero"))
+		}
+		if step.Height <= 0 {
+			return p.fail(path+".height", errors.New("must be greater than zero"))
+		}
+	case "convert":
+		mime, err := FormatMime(step.Format)
+		if err != nil {
+			return p.fail(path+".format", err)
+		}
+		if step.Quality != 0 && mime != "image/jpeg" {
+			return p.fail(path+".quality", errors.New("only applicable to jpeg"))
+		}
+		if step.Quality < 0 || step.Quality > 100 {
+			return p.fail(path+".quality", errors.New("must be between 1 and 100"))
+		}
+	case "optimize":
+		if step.MaxSize < 0 {
+			return p.fail(path+".max_size", errors.New("cannot be negative"))
+		}
+		if step.MinQuality < 0 || step.MinQuality > 100 {
+			return p.fail(path+".min_quality", errors.New("must be between 0 and 100"))
+		}
+		if step.MinSSIM < 0 || step.MinSSIM > 1 {
+			return p.fail(path+".min_ssim", errors.New("must be between 0 and 1"))
+		}
+	case "poster":
+		if step.Frame < 0 {
+			return p.fail(path+".frame", errors.New("cannot be negative"))
+		}
+	case "brightness", "contrast", "saturation":
+		if step.Percentage < -100 || step.Percentage > 100 {
+			return p.fail(path+".percentage", errors.New("must be between -100 and 100"))
+		}
+	case "gamma":
+		if step.Gamma <= 0 || step.Gamma > 10 {
+			return p.fail(path+".gamma", errors.New("must be between 0 and 10"))
+		}
+	case "hue":
+		if step.Angle < -180 || step.Angle > 180 {
+			return p.fail(path+".angle", errors.New("must be between -180 and 180"))
+		}
+	case "blur":
+		if step.Sigma <= 0 || step.Sigma > 100 {
+			return p.fail(path+".sigma", errors.New("must be between 0 and 100"))
+		}
+	case "sharpen":
+		return p.validSharpen(path, step)
+	case "pad":
+		for k, value := range []int{step.Top, step.Right, step.Bottom, step.Left} {
+			if value < 0 {
+				return p.fail(path+"."+[]string{"top", "right", "bottom", "left"}[k], errors.New("cannot be negative"))
+			}
+		}
+		if step.Top+step.Right+step.Bottom+step.Left == 0 {
+			return p.fail(path, errors.New("top, right, bottom or left is required"))
+		}
+		if err := validPixels(float64(step.Left)+float64(step.Right), float64(step.Top)+float64(step.Bottom)); err != nil {
+			return p.fail(path, err)
+		}
+	case "border":
+		if step.Width <= 0 {
+			return p.fail(path+".width", errors.New("must be greater than zero"))
+		}
+		if err := validPixels(2*float64(step.Width), 2*float64(step.Width)); err != nil {
+			return p.fail(path+".width", err)
+		}
+	case "round_corners":
+		if step.Radius <= 0 {
+			return p.fail(path+".radius", errors.New("must be greater than zero"))
+		}
+	}
+
+	if step.Color != "" {
+		if _, err := ParseColor(step.Color); err != nil {
+			return p.fail(path+".color", err)
+		}
+	}
+
+	return nil
+}
+
+// validPixels rejects a requested width x height over MAX_PIXELS, a zero
+// side counts as one pixel
+func validPixels(width, height float64) error {
+	if math.Max(width, 1)*math.Max(height, 1) > float64(MAX_PIXELS) {
+		return fmt.Errorf("%.0fx%.0f exceeds %d pixels", width, height, MAX_PIXELS)
+	}
+	return nil
+}
+
+// validSharpen ..
+func (p *Pipeline) validSharpen(path string, step Step) error {
+	if step.Sigma <= 0 {
+		return p.fail(path+".sigma", errors.New("must be greater than zero"))
+	}
+	if step.Amount < 0 || step.Amount > 10 {
+		return p.fail(path+".amount", errors.New("must be between 0 and 10"))
+	}
+	if step.Threshold < 0 || step.Threshold > 255 {
+		return p.fail(path+".threshold", errors.New("must be between 0 and 255"))
+	}
+
+	return nil
+}
+
+// fail ..
+func (p *Pipeline) fail(path string, err error) error {
+	e := &PipelineError{Path: path, Err: err}
+	if line, ok := p.lines[path]; ok {
+		e.Line = line
+	} else if index := strings.LastIndex(path, "."); index > 0 {
+		e.Line = p.lines[path[:index]]
+	}
+	return e
+}
+
+// Pixels returns the largest resolution in pixels the steps reach on a
+// width x height source
+func (p *Pipeline) Pixels(width, height int) int64 {
+	return p.pixels(float64(width), float64(height), false)
+}
+
+// pixels simulates the steps, a vector source is not rendered at its own
+// size when the first step resizes it
+func (p *Pipeline) pixels(w, h float64, vector bool) int64 {
+	peak := w * h
+	if vector && len(p.Steps) > 0 && p.Steps[0].Op == "resize" {
+		peak = 0
+	}
+
+	for _, step := range p.Steps {
+		switch step.Op {
+		case "resize":
+			cw, ch := step.resize().cover(w, h)
+			peak = math.Max(peak, cw*ch)
+			w, h = step.resize().size(w, h)
+		case "crop":
+			w, h = math.Min(w, float64(step.Width)), math.Min(h, float64(step.Height))
+		case "rotate":
+			sin, cos := math.Abs(math.Sin(step.Angle*math.Pi/180)), math.Abs(math.Cos(step.Angle*math.Pi/180))
+			w, h = math.Ceil(w*cos+h*sin), math.Ceil(w*sin+h*cos)
+		case "pad":
+			w, h = w+float64(step.Left+step.Right), h+float64(step.Top+step.Bottom)
+		case "border":
+			w, h = w+2*float64(step.Width), h+2*float64(step.Width)
+		case "circle_mask":
+			w, h = math.Min(w, h), math.Min(w, h)
+		}
+		peak = math.Max(peak, w*h)
+	}
+
+	if peak > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(peak)
+}
+
+// FramePixels is Pixels over every frame of an animation, each frame is
+// composited on the full canvas
+func (p *Pipeline) FramePixels(detail Detail) int64 {
+	pixels := p.pixels(float64(detail.Width), float64(detail.Height), detail.Mime == "image/svg+xml")
+	if detail.Frames <= 1 {
+		return pixels
+	}
+
+	if float64(pixels)*float64(detail.Frames) > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return pixels * int64(detail.Frames)
+}
+
+// Apply runs every step on the image and stops at the first error. The
+// image is rejected up front when a step would exceed MAX_PIXELS.
+func (p *Pipeline) Apply(i *Himage) *Himage {
+	if i.Error == nil && p.FramePixels(i.Detail) > int64(MAX_PIXELS) {
+		i.Error = fmt.Errorf("pipeline output exceeds %d pixels", MAX_PIXELS)
+		return i
+	}
+
+	for _, step := range p.Steps {
+		if i.Error != nil {
+			return i
+		}
+
+		switch step.Op {
+		case "resize":
+			i.Resize(step.resize())
+		case "crop":
+			i.Crop(Crop{Anchor: step.Anchor, Width: step.Width, Height: step.Height})
+		case "convert":
+			if step.Quality > 0 {
+				i.quality["jpg"] = step.Quality
+				i.quality["jpeg"] = step.Quality
+				i.qJPEG = step.Quality
+			}
+			i.Convert(step.Format)
+		case "optimize":
+			i.Optimize(Optimize{MaxSize: step.MaxSize, MinQuality: step.MinQuality, MinSSIM: step.MinSSIM})
+		case "rotate":
+			i.Rotate(step.Angle)
+		case "poster":
+			i.Poster(step.Frame)
+		case "brightness":
+			i.Brightness(step.Percentage)
+		case "contrast":
+			i.Contrast(step.Percentage)
+		case "gamma":
+			i.Gamma(step.Gamma)
+		case "saturation":
+			i.Saturation(step.Percentage)
+		case "hue":
+			i.Hue(step.Angle)
+		case "grayscale":
+			i.Grayscale()
+		case "sepia":
+			i.Sepia()
+		case "invert":
+			i.Invert()
+		case "blur":
+			i.Blur(step.Sigma)
+		case "sharpen":
+			i.Sharpen(step.sharpen())
+		case "pad":
+			i.Pad(step.Top, step.Right, step.Bottom, step.Left, step.color())
+		case "border":
+			i.Border(step.Width, step.color())
+		case "round_corners":
+			i.RoundCorners(step.Radius)
+		case "circle_mask":
+			i.CircleMask()
+		case "analyze":
+			i.Analyze()
+		default:
+			i.Error = fmt.Errorf("unknown pipeline op %q", step.Op)
+		}
+	}
+
+	return i
+}
+
+// resize ..
+func (s Step) resize() Resize {
+	return Resize{
+		Anchor:         s.Anchor,
+		Ratio:          s.Ratio,
+		Width:          s.Width,
+		Height:         s.Height,
+		WidthOriented:  s.WidthOriented,
+		HeightOriented: s.HeightOriented,
+		Maximize:       s.Maximize,
+		Minimize:       s.Minimize,
+		Sharpen:        s.sharpen(),
+		NoSharpen:      s.NoSharpen,
+	}
+}
+
+// color returns the parsed color, transparent when not set
+func (s Step) color() color.Color {
+	c, _ := ParseColor(s.Color)
+	return c
+}
+
+// sharpen ..
+func (s Step) sharpen() Sharpen {
+	return Sharpen{Sigma: s.Sigma, Amount: s.Amount, Threshold: s.Threshold}
+}
+
+// setFields returns the names of the non zero parameters
+func (s Step) setFields() []string {
+	fields := make([]string, 0)
+	set := map[string]bool{
+		"anchor":          s.Anchor != Center,
+		"ratio":           s.Ratio != 0,
+		"width":           s.Width != 0,
+		"height":          s.Height != 0,
+		"width_oriented":  s.WidthOriented,
+		"height_oriented": s.HeightOriented,
+		"maximize":        s.Maximize,
+		"minimize":        s.Minimize,
+		"no_sharpen":      s.NoSharpen,
+		"format":          s.Format != "",
+		"quality":         s.Quality != 0,
+		"max_size":        s.MaxSize != 0,
+		"min_quality":     s.MinQuality != 0,
+		"min_ssim":        s.MinSSIM != 0,
+		"angle":           s.Angle != 0,
+		"frame":           s.Frame != 0,
+		"percentage":      s.Percentage != 0,
+		"gamma":           s.Gamma != 0,
+		"sigma":           s.Sigma != 0,
+		"amount":          s.Amount != 0,
+		"threshold":       s.Threshold != 0,
+		"top":             s.Top != 0,
+		"right":           s.Right != 0,
+		"bottom":          s.Bottom != 0,
+		"left":            s.Left != 0,
+		"color":           s.Color != "",
+		"radius":          s.Radius != 0,
+	}
+	for field, ok := range set {
+		if ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// yamlLines maps field paths of a pipeline document to their source lines
+func yamlLines(root *yaml.Node) map[string]int {
+	lines := make(map[string]int)
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return lines
+	}
+
+	doc := root.Content[0]
+	for k := 0; k+1 < len(doc.Content); k += 2 {
+		if doc.Content[k].Value != "steps" {
+			continue
+		}
+		lines["steps"] = doc.Content[k].Line
+
+		for index, step := range doc.Content[k+1].Content {
+			path := fmt.Sprintf("steps[%d]", index)
+			lines[path] = step.Line
+			for f := 0; f+1 < len(step.Content); f += 2 {
+				lines[path+"."+step.Content[f].Value] = step.Content[f].Line
+			}
+		}
+	}
+
+	return lines
+}
+
+// position converts a byte offset to a line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// stringsContain ..
+func stringsContain(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
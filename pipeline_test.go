@@ -0,0 +1,269 @@
+package himage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePipelineJSON(t *testing.T) {
+	p, err := ParsePipelineJSON([]byte(`{"steps": [
+		{"op": "resize", "width": 200, "height": 200, "anchor": "top-left"},
+		{"op": "convert", "format": "jpg", "quality": 80}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 2 {
+		t.Error(errors.New("step count is not valid"))
+	}
+
+	if p.Steps[0].Anchor != TopLeft {
+		t.Error(errors.New("anchor is not valid"))
+	}
+}
+
+func TestParsePipelineJSONInvalidStep(t *testing.T) {
+	_, err := ParsePipelineJSON([]byte(`{"steps": [
+		{"op": "resize", "width": 200},
+		{"op": "crop", "width": 100, "height": -1}
+	]}`))
+
+	e, ok := err.(*PipelineError)
+	if !ok {
+		t.Fatal(errors.New("pipeline error is not valid"))
+	}
+
+	if e.Path != "steps[1].height" {
+		t.Error(errors.New("error path is not valid"))
+	}
+}
+
+func TestParsePipelineJSONSyntaxError(t *testing.T) {
+	_, err := ParsePipelineJSON([]byte("{\"steps\": [\n{\"op\": }\n]}"))
+
+	e, ok := err.(*PipelineError)
+	if !ok {
+		t.Fatal(errors.New("pipeline error is not valid"))
+	}
+
+	if e.Line != 2 {
+		t.Error(errors.New("error line is not valid"))
+	}
+}
+
+func TestParsePipelineYAML(t *testing.T) {
+	p, err := ParsePipelineYAML([]byte(`
+steps:
+  - op: crop
+    width: 100
+    height: 100
+    anchor: center
+  - op: optimize
+    max_size: 10000
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 2 || p.Steps[1].MaxSize != 10000 {
+		t.Error(errors.New("steps are not valid"))
+	}
+}
+
+func TestParsePipelineYAMLInvalidField(t *testing.T) {
+	_, err := ParsePipelineYAML([]byte(`
+steps:
+  - op: resize
+    width: 100
+  - op: convert
+    format: png
+    width: 100
+`))
+
+	e, ok := err.(*PipelineError)
+	if !ok {
+		t.Fatal(errors.New("pipeline error is not valid"))
+	}
+
+	if e.Path != "steps[1].width" || e.Line != 7 {
+		t.Error(errors.New("error location is not valid"))
+	}
+}
+
+func TestParsePipelineYAMLUnknownField(t *testing.T) {
+	_, err := ParsePipelineYAML([]byte(`
+steps:
+  - op: resize
+    widht: 100
+`))
+	if err == nil {
+		t.Error(errors.New("unknown field should be rejected"))
+	}
+}
+
+func TestPipelineApply(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	p, err := ParsePipelineJSON([]byte(`{"steps": [
+		{"op": "resize", "width": 400},
+		{"op": "crop", "width": 200, "height": 100},
+		{"op": "convert", "format": "jpeg", "quality": 70}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).SetDestination(dst)
+	_, err = p.Apply(hImage).Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewHimageWithPath(hImage.Output())
+	if o.Detail.Width != 200 || o.Detail.Height != 100 {
+		t.Error(errors.New("output resolution is not valid"))
+	}
+
+	if o.Detail.Mime != "image/jpeg" || filepath.Ext(hImage.Output()) != ".jpg" {
+		t.Error(errors.New("output format is not valid"))
+	}
+}
+
+func TestPipelineResizeRatio(t *testing.T) {
+	cases := []struct {
+		step          string
+		width, height int
+	}{
+		{`{"op": "resize", "ratio": 4}`, 480, 320},
+		{`{"op": "resize", "ratio": 4, "maximize": true}`, 800, 532},
+		{`{"op": "resize", "ratio": 2, "width_oriented": true}`, 320, 213},
+		{`{"op": "resize", "ratio": 2, "height_oriented": true, "maximize": true}`, 960, 639},
+	}
+
+	for _, c := range cases {
+		p, err := ParsePipelineJSON([]byte(`{"steps": [` + c.step + `]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hImage := p.Apply(NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")))
+		if hImage.Error != nil {
+			t.Fatal(hImage.Error)
+		}
+		hImage.Finish()
+
+		if hImage.Detail.Width != c.width || hImage.Detail.Height != c.height {
+			t.Errorf("%s resolution %dx%d is not valid", c.step, hImage.Detail.Width, hImage.Detail.Height)
+		}
+	}
+
+	_, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "resize", "maximize": true}]}`))
+	if e, ok := err.(*PipelineError); !ok || e.Path != "steps[0]" {
+		t.Error(errors.New("resize without a size should fail"))
+	}
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "10x10.png")).Resize(Resize{})
+	if hImage.Error == nil {
+		t.Error(errors.New("empty resize should fail"))
+	}
+	hImage.Finish()
+}
+
+func TestHimageOptimize(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).
+		SetDestination(dst).
+		Optimize(Optimize{MaxSize: 100 * 1024, MinQuality: 10})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if hImage.Detail.Size > 100*1024 || hImage.qJPEG >= 100 {
+		t.Error(errors.New("optimized size is not valid"))
+	}
+	hImage.Finish()
+
+	source, err := os.Stat(filepath.Join("test-files", "640x426.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hImage = NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).Optimize(Optimize{})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	if hImage.Detail.Size > source.Size() {
+		t.Error(errors.New("optimize should not grow the image"))
+	}
+	if stat, err := os.Stat(hImage.tempPath); err != nil || stat.Size() != hImage.Detail.Size {
+		t.Error(errors.New("optimized detail size is not valid"))
+	}
+	hImage.Finish()
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "1920x1280.jpeg")).
+		SetDestination(dst).
+		Optimize(Optimize{MaxSize: 100, MinQuality: 90})
+	if hImage.Error == nil {
+		t.Error(errors.New("quality floor should not be exceeded"))
+	}
+	hImage.Finish()
+
+	err = (Optimize{MinQuality: 101}).Valid()
+	_, perr := ParsePipelineJSON([]byte(`{"steps": [{"op": "optimize", "min_quality": 101}]}`))
+	if err == nil || perr == nil || !strings.Contains(err.Error(), "between 0 and 100") || !strings.Contains(perr.Error(), "between 0 and 100") {
+		t.Error(errors.New("min quality range is not valid"))
+	}
+	if (Optimize{MinQuality: 0}).Valid() != nil {
+		t.Error(errors.New("zero min quality should be valid"))
+	}
+}
+
+func TestAnchorUnmarshalText(t *testing.T) {
+	var a Anchor
+	if err := a.UnmarshalText([]byte("bottom_right")); err != nil || a != BottomRight {
+		t.Error(errors.New("anchor is not valid"))
+	}
+
+	if err := a.UnmarshalText([]byte("middle")); err == nil {
+		t.Error(errors.New("invalid anchor should be rejected"))
+	}
+}
+
+func TestPipelinePixels(t *testing.T) {
+	p := &Pipeline{Steps: []Step{
+		{Op: "resize", Width: 200},
+		{Op: "pad", Top: 10, Bottom: 10},
+		{Op: "crop", Width: 100, Height: 100},
+	}}
+	if pixels := p.Pixels(400, 300); pixels != 400*300 {
+		t.Error(errors.New("source pixels are not valid"))
+	}
+	if pixels := p.Pixels(100, 50); pixels != 200*120 {
+		t.Error(errors.New("grown pixels are not valid"))
+	}
+
+	if _, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "resize", "width": 100000, "height": 100000}]}`)); err == nil {
+		t.Error(errors.New("resize over the pixel limit should be rejected"))
+	}
+
+	if _, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "border", "width": 9223372036854775807}]}`)); err == nil {
+		t.Error(errors.New("border over the pixel limit should be rejected"))
+	}
+
+	p, err := ParsePipelineJSON([]byte(`{"steps": [{"op": "resize", "width": 20000}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hImage := p.Apply(NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")))
+	defer hImage.Finish()
+	if hImage.Error == nil {
+		t.Error(errors.New("pipeline over the pixel limit should be rejected"))
+	}
+}
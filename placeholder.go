@@ -0,0 +1,130 @@
+package himage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// LQIP is a low quality image placeholder
+type LQIP struct {
+	// DataURI is the base64 encoded placeholder image
+	DataURI string
+	Width   int
+	Height  int
+	// Color is the dominant color of the image
+	Color color.NRGBA
+}
+
+// Hex returns the dominant color as #rrggbb
+func (l LQIP) Hex() string {
+	return hexColor(l.Color)
+}
+
+// Placeholder returns a tiny blurred copy of the current image as a data
+// URI and its dominant color. The working image is left unchanged.
+func (i *Himage) Placeholder(option Placeholder) (LQIP, error) {
+	if err := option.Valid(); err != nil {
+		return LQIP{}, err
+	}
+
+	if i.Error != nil {
+		return LQIP{}, i.Error
+	}
+
+	src, err := i.image()
+	if err != nil {
+		return LQIP{}, err
+	}
+
+	width := option.Width
+	if width == 0 {
+		width = 16
+	}
+	im := imaging.Resize(src, width, 0, imaging.Lanczos)
+	if !option.NoBlur {
+		sigma := option.Sigma
+		if sigma == 0 {
+			sigma = 1
+		}
+		im = imaging.Blur(im, sigma)
+	}
+
+	mime, err := FormatMime(option.Format)
+	if option.Format == "" {
+		mime, err = "image/jpeg", nil
+	}
+	if err != nil {
+		return LQIP{}, err
+	}
+
+	quality := option.Quality
+	if quality == 0 {
+		quality = 50
+	}
+
+	buffer := new(bytes.Buffer)
+	switch mime {
+	case "image/jpeg":
+		err = imaging.Encode(buffer, im, imaging.JPEG, imaging.JPEGQuality(quality))
+	case "image/gif":
+		err = imaging.Encode(buffer, im, imaging.GIF)
+	default:
+		mime = "image/png"
+		err = imaging.Encode(buffer, im, imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
+	}
+	if err != nil {
+		return LQIP{}, err
+	}
+
+	return LQIP{
+		DataURI: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buffer.Bytes()),
+		Width:   im.Bounds().Dx(),
+		Height:  im.Bounds().Dy(),
+		Color:   dominantColor(src),
+	}, nil
+}
+
+// dominantColor averages the most populated bucket of a 4 bit per
+// channel histogram of a downsampled copy, transparent pixels are skipped
+func dominantColor(src image.Image) color.NRGBA {
+	im := imaging.Fit(src, INSPECT_SIZE, INSPECT_SIZE, imaging.Box)
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[int]*bucket)
+	best := -1
+	for k := 0; k < len(im.Pix); k += 4 {
+		if im.Pix[k+3] < 128 {
+			continue
+		}
+
+		r, g, b := int(im.Pix[k]), int(im.Pix[k+1]), int(im.Pix[k+2])
+		key := r>>4<<8 | g>>4<<4 | b>>4
+		bu, ok := buckets[key]
+		if !ok {
+			bu = new(bucket)
+			buckets[key] = bu
+		}
+		bu.count++
+		bu.r += r
+		bu.g += g
+		bu.b += b
+
+		if best < 0 || bu.count > buckets[best].count || bu.count == buckets[best].count && key < best {
+			best = key
+		}
+	}
+
+	if best < 0 {
+		return color.NRGBA{}
+	}
+
+	bu := buckets[best]
+	return color.NRGBA{R: uint8(bu.r / bu.count), G: uint8(bu.g / bu.count), B: uint8(bu.b / bu.count), A: 255}
+}
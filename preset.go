@@ -0,0 +1,93 @@
+package himage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// presets registered named pipelines
+var presets = struct {
+	sync.RWMutex
+	pipelines map[string]*Pipeline
+}{pipelines: make(map[string]*Pipeline)}
+
+// RegisterPreset validates and registers a named pipeline, a name can only be registered once
+func RegisterPreset(name string, p *Pipeline) error {
+	if name == "" {
+		return fmt.Errorf("preset name is empty")
+	}
+
+	if p == nil {
+		return fmt.Errorf("preset %q pipeline is nil", name)
+	}
+
+	if err := p.Valid(); err != nil {
+		return fmt.Errorf("preset %q: %w", name, err)
+	}
+
+	presets.Lock()
+	defer presets.Unlock()
+
+	if _, ok := presets.pipelines[name]; ok {
+		return fmt.Errorf("preset %q is already registered", name)
+	}
+	presets.pipelines[name] = p.copy()
+
+	return nil
+}
+
+// MustRegisterPreset is like RegisterPreset but panics on error, for use at startup
+func MustRegisterPreset(name string, p *Pipeline) {
+	if err := RegisterPreset(name, p); err != nil {
+		panic(err)
+	}
+}
+
+// LookupPreset returns a copy of the registered pipeline
+func LookupPreset(name string) (*Pipeline, bool) {
+	presets.RLock()
+	defer presets.RUnlock()
+
+	p, ok := presets.pipelines[name]
+	if !ok {
+		return nil, false
+	}
+	return p.copy(), true
+}
+
+// Presets returns the registered preset names in sorted order
+func Presets() []string {
+	presets.RLock()
+	defer presets.RUnlock()
+
+	names := make([]string, 0, len(presets.pipelines))
+	for name := range presets.pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ApplyPreset runs the registered pipeline on the image
+func (i *Himage) ApplyPreset(name string) *Himage {
+	if i.Error != nil {
+		return i
+	}
+
+	p, ok := LookupPreset(name)
+	if !ok {
+		i.Error = fmt.Errorf("preset %q is not registered", name)
+		return i
+	}
+
+	return p.Apply(i)
+}
+
+// copy ..
+func (p *Pipeline) copy() *Pipeline {
+	steps := make([]Step, len(p.Steps))
+	copy(steps, p.Steps)
+	return &Pipeline{Steps: steps}
+}
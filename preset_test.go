@@ -0,0 +1,77 @@
+package himage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterPreset(t *testing.T) {
+	avatar := &Pipeline{Steps: []Step{
+		{Op: "resize", Width: 64, Height: 64, Anchor: Top},
+		{Op: "convert", Format: "png"},
+	}}
+
+	if err := RegisterPreset("test-avatar", avatar); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterPreset("test-avatar", avatar); err == nil {
+		t.Error(errors.New("preset should not be registered twice"))
+	}
+
+	avatar.Steps[0].Width = 1
+	p, ok := LookupPreset("test-avatar")
+	if !ok || p.Steps[0].Width != 64 {
+		t.Error(errors.New("registered preset should not be changed"))
+	}
+
+	found := false
+	for _, name := range Presets() {
+		if name == "test-avatar" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error(errors.New("preset is not listed"))
+	}
+}
+
+func TestRegisterPresetInvalidPipeline(t *testing.T) {
+	err := RegisterPreset("test-invalid", &Pipeline{Steps: []Step{{Op: "unknown"}}})
+	if err == nil {
+		t.Error(errors.New("invalid pipeline should be rejected"))
+	}
+
+	if _, ok := LookupPreset("test-invalid"); ok {
+		t.Error(errors.New("invalid preset should not be registered"))
+	}
+}
+
+func TestHimageApplyPreset(t *testing.T) {
+	dst, _ := ioutil.TempDir("", "himage")
+	defer os.RemoveAll(dst)
+
+	MustRegisterPreset("test-thumbnail", &Pipeline{Steps: []Step{
+		{Op: "resize", Width: 120, Height: 80, Anchor: Top},
+	}})
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+		SetDestination(dst).
+		ApplyPreset("test-thumbnail")
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if hImage.Detail.Width != 120 || hImage.Detail.Height != 80 {
+		t.Error(errors.New("preset resolution is not valid"))
+	}
+	hImage.Finish()
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).ApplyPreset("test-missing")
+	if hImage.Error == nil {
+		t.Error(errors.New("missing preset should fail"))
+	}
+}
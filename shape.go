@@ -0,0 +1,186 @@
+package himage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseColor parses #rgb, #rgba, #rrggbb, #rrggbbaa or "transparent"
+func ParseColor(s string) (color.NRGBA, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "transparent" {
+		return color.NRGBA{}, nil
+	}
+
+	v = strings.TrimPrefix(v, "#")
+	if len(v) == 3 || len(v) == 4 {
+		expanded := make([]byte, 0, 2*len(v))
+		for k := 0; k < len(v); k++ {
+			expanded = append(expanded, v[k], v[k])
+		}
+		v = string(expanded)
+	}
+	if len(v) == 6 {
+		v += "ff"
+	}
+
+	n, err := strconv.ParseUint(v, 16, 32)
+	if err != nil || len(v) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// hexColor formats c as #rrggbb
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Pad extends the canvas by the given pixels filled with c, transparent when nil
+func (i *Himage) Pad(top, right, bottom, left int, c color.Color) *Himage {
+	if top < 0 || right < 0 || bottom < 0 || left < 0 {
+		i.Error = errors.New("padding cannot be negative")
+		return i
+	}
+
+	if (float64(i.Detail.Width)+float64(left)+float64(right))*(float64(i.Detail.Height)+float64(top)+float64(bottom)) > float64(MAX_PIXELS) {
+		i.Error = fmt.Errorf("padded resolution exceeds %d pixels", MAX_PIXELS)
+		return i
+	}
+
+	if c == nil {
+		c = color.Transparent
+	}
+
+	_, _, _, a := c.RGBA()
+	return i.alphaTransform(a < 0xffff, func(src image.Image) *image.NRGBA {
+		b := src.Bounds()
+		dst := image.NewNRGBA(image.Rect(0, 0, b.Dx()+left+right, b.Dy()+top+bottom))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		draw.Draw(dst, image.Rect(left, top, left+b.Dx(), top+b.Dy()), src, b.Min, draw.Src)
+		return dst
+	})
+}
+
+// Border surrounds the image with a width pixels border of c
+func (i *Himage) Border(width int, c color.Color) *Himage {
+	if width <= 0 {
+		i.Error = errors.New("border width must be greater than zero")
+		return i
+	}
+
+	return i.Pad(width, width, width, width, c)
+}
+
+// RoundCorners makes the corners outside radius transparent
+func (i *Himage) RoundCorners(radius int) *Himage {
+	if radius <= 0 {
+		i.Error = errors.New("corner radius must be greater than zero")
+		return i
+	}
+
+	return i.alphaTransform(true, func(src image.Image) *image.NRGBA {
+		dst := imaging.Clone(src)
+		w, h := dst.Bounds().Dx(), dst.Bounds().Dy()
+		r := radius
+		if r > w/2 {
+			r = w / 2
+		}
+		if r > h/2 {
+			r = h / 2
+		}
+
+		rf := float64(r)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				cx, cy := -1.0, -1.0
+				if x < r {
+					cx = rf
+				} else if x >= w-r {
+					cx = float64(w - r)
+				}
+				if y < r {
+					cy = rf
+				} else if y >= h-r {
+					cy = float64(h - r)
+				}
+				if cx < 0 || cy < 0 {
+					continue
+				}
+				mask(dst, x, y, coverage(float64(x)+0.5-cx, float64(y)+0.5-cy, rf))
+			}
+		}
+		return dst
+	})
+}
+
+// CircleMask crops the centered square of the shorter side and makes
+// everything outside its inscribed circle transparent
+func (i *Himage) CircleMask() *Himage {
+	return i.alphaTransform(true, func(src image.Image) *image.NRGBA {
+		side := src.Bounds().Dx()
+		if src.Bounds().Dy() < side {
+			side = src.Bounds().Dy()
+		}
+
+		dst := imaging.CropCenter(src, side, side)
+		r := float64(side) / 2
+		for y := 0; y < side; y++ {
+			for x := 0; x < side; x++ {
+				mask(dst, x, y, coverage(float64(x)+0.5-r, float64(y)+0.5-r, r))
+			}
+		}
+		return dst
+	})
+}
+
+// alphaTransform runs fn and keeps the result in an alpha capable format
+// following the alpha policy when transparent is set
+func (i *Himage) alphaTransform(transparent bool, fn func(src image.Image) *image.NRGBA) *Himage {
+	if !transparent {
+		return i.transform(fn)
+	}
+
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Error != nil {
+		return i
+	}
+
+	_, encodable := formats[i.Detail.Mime]
+	if encodable && !alphaFormats[i.Detail.Mime] && i.alphaPolicy == AlphaError {
+		i.Error = fmt.Errorf("%s cannot store transparency", i.Detail.Mime)
+		return i
+	}
+
+	return i.transform(func(src image.Image) *image.NRGBA {
+		if _, ok := formats[i.Detail.Mime]; ok && !alphaFormats[i.Detail.Mime] {
+			i.retarget("image/png")
+		}
+		return fn(src)
+	})
+}
+
+// coverage is the antialiased share of a pixel at dx, dy inside a circle of radius r
+func coverage(dx, dy, r float64) float64 {
+	return math.Max(0, math.Min(1, r+0.5-math.Hypot(dx, dy)))
+}
+
+// mask scales the alpha of a pixel by f
+func mask(im *image.NRGBA, x, y int, f float64) {
+	if f >= 1 {
+		return
+	}
+	k := im.PixOffset(x, y) + 3
+	im.Pix[k] = uint8(math.Round(float64(im.Pix[k]) * f))
+}
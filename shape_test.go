@@ -0,0 +1,148 @@
+package himage
+
+import (
+	"errors"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff000080")
+	if err != nil || c != (color.NRGBA{R: 255, A: 128}) {
+		t.Error(errors.New("color is not valid"))
+	}
+
+	c, err = ParseColor("0f0")
+	if err != nil || c != (color.NRGBA{G: 255, A: 255}) {
+		t.Error(errors.New("short color is not valid"))
+	}
+
+	if _, err := ParseColor("#ff00"); err != nil {
+		t.Error(errors.New("short alpha color is not valid"))
+	}
+
+	if _, err := ParseColor("#zzzzzz"); err == nil {
+		t.Error(errors.New("invalid color should fail"))
+	}
+}
+
+func TestHimagePadBorder(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+		Pad(10, 20, 30, 40, color.White).
+		Border(5, color.Black)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Width != 710 || hImage.Detail.Height != 476 || hImage.Detail.Mime != "image/jpeg" {
+		t.Error(errors.New("padded detail is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).Pad(1, 1, 1, 1, nil)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/png" {
+		t.Error(errors.New("transparent padding should switch to png"))
+	}
+}
+
+func TestHimageCircleMask(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).CircleMask()
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/png" || hImage.Detail.Width != 426 || hImage.Detail.Height != 426 {
+		t.Error(errors.New("circle mask detail is not valid"))
+	}
+
+	im, err := hImage.image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, corner := im.At(0, 0).RGBA()
+	_, _, _, center := im.At(213, 213).RGBA()
+	if corner != 0 || center != 0xffff {
+		t.Error(errors.New("circle mask alpha is not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "640x426.jpeg")).
+		SetAlphaPolicy(AlphaError).
+		RoundCorners(20)
+	if hImage.Error == nil {
+		t.Error(errors.New("alpha error policy should fail"))
+	}
+	hImage.Finish()
+}
+
+func TestHimageRoundCorners(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).RoundCorners(50)
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	im, err := hImage.image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, corner := im.At(849, 565).RGBA()
+	_, _, _, edge := im.At(425, 0).RGBA()
+	if corner != 0 || edge == 0 {
+		t.Error(errors.New("rounded corners are not valid"))
+	}
+}
+
+func TestPipelineShapeSteps(t *testing.T) {
+	p, err := ParsePipelineYAML([]byte(`steps:
+  - op: pad
+    top: 4
+    color: "#ffffff"
+  - op: border
+    width: 2
+    color: "#000"
+  - op: circle_mask
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hImage := p.Apply(NewHimageWithPath(filepath.Join("test-files", "10x10.png")))
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	hImage.Finish()
+
+	_, err = ParsePipelineJSON([]byte(`{"steps": [{"op": "border", "width": 2, "color": "blue"}]}`))
+	if e, ok := err.(*PipelineError); !ok || e.Path != "steps[0].color" {
+		t.Error(errors.New("invalid color should fail"))
+	}
+}
+
+func TestHimageResizePixelLimit(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "10x10.png")).Resize(Resize{Width: 100000, Height: 100000})
+	defer hImage.Finish()
+	if hImage.Error == nil {
+		t.Error(errors.New("resize over the pixel limit should be rejected"))
+	}
+
+	svg := NewHimageWithPath(filepath.Join("test-files", "120x60.svg")).Resize(Resize{Width: 40000})
+	defer svg.Finish()
+	if svg.Error == nil {
+		t.Error(errors.New("svg resize over the pixel limit should be rejected"))
+	}
+
+	padded := NewHimageWithPath(filepath.Join("test-files", "10x10.png")).Pad(20000, 20000, 0, 0, nil)
+	defer padded.Finish()
+	if padded.Error == nil {
+		t.Error(errors.New("pad over the pixel limit should be rejected"))
+	}
+}
@@ -0,0 +1,400 @@
+package himage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ViewBox is the SVG user coordinate system
+type ViewBox struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// svgBlocked elements removed with their content by SanitizeSVG
+var svgBlocked = map[string]bool{
+	"script":        true,
+	"style":         true,
+	"foreignObject": true,
+	"iframe":        true,
+	"object":        true,
+	"embed":         true,
+	"audio":         true,
+	"video":         true,
+	"handler":       true,
+	"listener":      true,
+	// animations can set href and other attributes to external values
+	"animate":          true,
+	"set":              true,
+	"animateMotion":    true,
+	"animateTransform": true,
+}
+
+// svgUnits absolute length units in pixels
+var svgUnits = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 4.0 / 3.0,
+	"pc": 16,
+	"mm": 96 / 25.4,
+	"cm": 96 / 2.54,
+	"in": 96,
+	"em": 16,
+	"ex": 8,
+}
+
+// svgInfo intrinsic size of a sanitized document
+type svgInfo struct {
+	width   int
+	height  int
+	viewBox ViewBox
+}
+
+// SanitizeSVG strips scripts, styles, animations, event handlers and external
+// references from an SVG document. Comments, processing instructions
+// and doctypes are dropped, the root width, height and viewBox are
+// rewritten to the intrinsic size.
+func SanitizeSVG(data []byte) ([]byte, error) {
+	out, _, err := sanitizeSVG(data)
+	return out, err
+}
+
+// sanitizeSVG ..
+func sanitizeSVG(data []byte) ([]byte, svgInfo, error) {
+	var info svgInfo
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	out := new(bytes.Buffer)
+	root := true
+	skip := 0
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, info, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skip > 0 || svgBlocked[t.Name.Local] {
+				skip++
+				continue
+			}
+
+			attrs := svgAttrs(t.Attr)
+			if root {
+				if t.Name.Local != "svg" {
+					return nil, info, errors.New("svg root element is missing")
+				}
+				info, attrs = svgRoot(attrs)
+				root = false
+			}
+
+			out.WriteString("<" + svgName(t.Name))
+			for _, attr := range attrs {
+				out.WriteString(" " + svgName(attr.Name) + `="`)
+				xml.EscapeText(out, []byte(attr.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + svgName(t.Name) + ">")
+		case xml.CharData:
+			if skip == 0 && !root {
+				xml.EscapeText(out, t)
+			}
+		}
+	}
+
+	if root {
+		return nil, info, errors.New("svg root element is missing")
+	}
+
+	return out.Bytes(), info, nil
+}
+
+// svgAttrs drops event handlers, scripted values and external references
+func svgAttrs(attrs []xml.Attr) []xml.Attr {
+	safe := make([]xml.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+
+		if strings.HasPrefix(name, "on") || attr.Name.Space == "xml" && name == "base" {
+			continue
+		}
+
+		if strings.Contains(value, "javascript:") {
+			continue
+		}
+
+		if (name == "href" || name == "src") && !strings.HasPrefix(value, "#") {
+			continue
+		}
+
+		if strings.Contains(value, "url(") && !strings.Contains(value, "url(#") {
+			continue
+		}
+
+		safe = append(safe, attr)
+	}
+	return safe
+}
+
+// svgRoot computes the intrinsic size and rewrites the root sizing attributes
+func svgRoot(attrs []xml.Attr) (svgInfo, []xml.Attr) {
+	var width, height, viewBox string
+	rest := make([]xml.Attr, 0, len(attrs)+3)
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "viewBox":
+			viewBox = attr.Value
+		default:
+			rest = append(rest, attr)
+		}
+	}
+
+	w, wok := svgLength(width)
+	h, hok := svgLength(height)
+	vb, vbok := svgViewBox(viewBox)
+
+	switch {
+	case wok && hok:
+	case vbok && wok:
+		h = w * vb.Height / vb.Width
+	case vbok && hok:
+		w = h * vb.Width / vb.Height
+	case vbok:
+		w, h = vb.Width, vb.Height
+	default:
+		if !wok {
+			w = 300
+		}
+		if !hok {
+			h = 150
+		}
+	}
+
+	if !vbok {
+		vb = ViewBox{Width: w, Height: h}
+	}
+
+	info := svgInfo{
+		width:   svgPixels(w),
+		height:  svgPixels(h),
+		viewBox: vb,
+	}
+
+	rest = append(rest,
+		xml.Attr{Name: xml.Name{Local: "width"}, Value: strconv.Itoa(info.width)},
+		xml.Attr{Name: xml.Name{Local: "height"}, Value: strconv.Itoa(info.height)},
+		xml.Attr{Name: xml.Name{Local: "viewBox"}, Value: fmt.Sprintf("%g %g %g %g", vb.X, vb.Y, vb.Width, vb.Height)},
+	)
+
+	return info, rest
+}
+
+// svgPixels rounds a length to a side between 1 and MAX_PIXELS
+func svgPixels(v float64) int {
+	if math.IsNaN(v) || v < 1 {
+		return 1
+	}
+	return int(math.Min(float64(MAX_PIXELS), math.Round(v)))
+}
+
+// svgLength parses an absolute length in pixels, percentages are not absolute
+func svgLength(v string) (float64, bool) {
+	v = strings.TrimSpace(v)
+	end := len(v)
+	for end > 0 && (v[end-1] >= 'a' && v[end-1] <= 'z') {
+		end--
+	}
+
+	scale, ok := svgUnits[v[end:]]
+	if !ok {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(v[:end], 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f * scale, true
+}
+
+// svgViewBox ..
+func svgViewBox(v string) (ViewBox, bool) {
+	fields := strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) != 4 {
+		return ViewBox{}, false
+	}
+
+	values := make([]float64, 4)
+	for k, field := range fields {
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return ViewBox{}, false
+		}
+		values[k] = f
+	}
+
+	if values[2] <= 0 || values[3] <= 0 {
+		return ViewBox{}, false
+	}
+	return ViewBox{X: values[0], Y: values[1], Width: values[2], Height: values[3]}, true
+}
+
+// svgName ..
+func svgName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// readSVG reads a size limited document
+func readSVG(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, SVG_MAX_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > SVG_MAX_SIZE {
+		return nil, fmt.Errorf("svg exceeds %d bytes", SVG_MAX_SIZE)
+	}
+	return data, nil
+}
+
+// svgConfig fills the detail of an SVG document
+func (i *Himage) svgConfig(r io.Reader) *Himage {
+	data, err := readSVG(r)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	_, info, err := sanitizeSVG(data)
+	if err != nil {
+		i.Error = &UnsupportedError{Mime: "image/svg+xml", Reason: err.Error()}
+		return i
+	}
+
+	i.Detail.Width = info.width
+	i.Detail.Height = info.height
+	i.Detail.ViewBox = info.viewBox
+
+	return i
+}
+
+// sanitizeTemp rewrites the moved SVG with its sanitized document
+func (i *Himage) sanitizeTemp() *Himage {
+	data, err := ioutil.ReadFile(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	out, err := SanitizeSVG(data)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	if err := ioutil.WriteFile(i.tempPath, out, 0644); err != nil {
+		i.Error = err
+		return i
+	}
+	i.Detail.Size = int64(len(out))
+
+	return i
+}
+
+// renderSVG rasterizes a document at the given size
+func renderSVG(data []byte, width int, height int) (*image.NRGBA, error) {
+	if width <= 0 || height <= 0 || int64(width)*int64(height) > int64(MAX_PIXELS) {
+		return nil, fmt.Errorf("invalid svg raster size %dx%d", width, height)
+	}
+
+	out, _, err := sanitizeSVG(data)
+	if err != nil {
+		return nil, err
+	}
+
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(out), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, err
+	}
+	icon.SetTarget(0, 0, float64(width), float64(height))
+
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, rgba, rgba.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+
+	return imaging.Clone(rgba), nil
+}
+
+// Rasterize renders an SVG at the given size as PNG, a zero dimension
+// keeps the intrinsic aspect ratio. Raster images are left unchanged.
+func (i *Himage) Rasterize(width int, height int) *Himage {
+	if !i.moved {
+		i.move()
+	}
+
+	if i.Error != nil || i.Detail.Mime != "image/svg+xml" {
+		return i
+	}
+
+	if width <= 0 && height <= 0 {
+		width, height = i.Detail.Width, i.Detail.Height
+	} else if width <= 0 {
+		width = int(math.Max(1, math.Round(float64(height)*float64(i.Detail.Width)/float64(i.Detail.Height))))
+	} else if height <= 0 {
+		height = int(math.Max(1, math.Round(float64(width)*float64(i.Detail.Height)/float64(i.Detail.Width))))
+	}
+
+	data, err := ioutil.ReadFile(i.tempPath)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	im, err := renderSVG(data, width, height)
+	if err != nil {
+		i.Error = err
+		return i
+	}
+
+	i.retarget("image/png")
+	i.save(im)
+	if i.Error == nil {
+		i.Detail.Width = width
+		i.Detail.Height = height
+	}
+
+	return i
+}
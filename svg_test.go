@@ -0,0 +1,145 @@
+package himage
+
+import (
+	"errors"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	data, _ := ioutil.ReadFile(filepath.Join("test-files", "120x60.svg"))
+	out, err := SanitizeSVG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(out)
+	for _, blocked := range []string{"<script", "alert", "onload", "onclick", "example.com"} {
+		if strings.Contains(s, blocked) {
+			t.Error(errors.New("sanitized svg is not valid: " + blocked))
+		}
+	}
+
+	if !strings.Contains(s, "<circle") {
+		t.Error(errors.New("sanitized svg content is not valid"))
+	}
+
+	if _, err := SanitizeSVG([]byte(`<html><body/></html>`)); err == nil {
+		t.Error(errors.New("non svg document should fail"))
+	}
+}
+
+func TestHimageSVGDetail(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "120x60.svg"))
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+
+	if hImage.Detail.Mime != "image/svg+xml" || hImage.Detail.Width != 120 || hImage.Detail.Height != 60 {
+		t.Error(errors.New("svg detail is not valid"))
+	}
+
+	if hImage.Detail.ViewBox != (ViewBox{Width: 240, Height: 120}) {
+		t.Error(errors.New("svg viewBox is not valid"))
+	}
+}
+
+func TestHimageRasterize(t *testing.T) {
+	hImage := NewHimageWithPath(filepath.Join("test-files", "120x60.svg")).
+		Resize(Resize{Width: 60})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/png" || hImage.Detail.Width != 60 || hImage.Detail.Height != 30 {
+		t.Error(errors.New("rasterized detail is not valid"))
+	}
+
+	f, _ := os.Open(hImage.tempPath)
+	defer f.Close()
+	im, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, b, _ := im.At(30, 15).RGBA()
+	if b>>8 < 200 || r>>8 > 50 {
+		t.Error(errors.New("rasterized pixels are not valid"))
+	}
+
+	hImage = NewHimageWithPath(filepath.Join("test-files", "120x60.svg")).
+		Crop(Crop{Width: 40, Height: 40}).
+		Convert("jpeg")
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Mime != "image/jpeg" || hImage.Detail.Width != 40 {
+		t.Error(errors.New("converted svg is not valid"))
+	}
+}
+
+func TestSanitizeSVGAnimations(t *testing.T) {
+	out, err := SanitizeSVG([]byte(`<svg viewBox="0 0 10 10"><a href="#a"><animate attributeName="href" to="https://evil/x"/><text>x</text></a>` +
+		`<set attributeName="href" to="https://evil/y"/><animateMotion values="https://evil/z"/><animateTransform from="https://evil/w"/></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(out), "evil") || strings.Contains(string(out), "animate") || !strings.Contains(string(out), "<text>") {
+		t.Error(errors.New("sanitized animations are not valid: " + string(out)))
+	}
+}
+
+func TestSanitizeSVGHugeSize(t *testing.T) {
+	out, err := SanitizeSVG([]byte(`<svg width="1e300" height="1e-300"></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(out), `width="100000000"`) || !strings.Contains(string(out), `height="1"`) {
+		t.Error(errors.New("clamped size is not valid: " + string(out)))
+	}
+}
+
+func TestHimageRasterizeLargeSVG(t *testing.T) {
+	f, err := ioutil.TempFile("", "himage*.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="40000" height="20000" viewBox="0 0 40000 20000"><!-- comment --><rect width="40000" height="20000" fill="red"/></svg>`)
+	f.Close()
+
+	hImage := NewHimageWithPath(f.Name())
+	if hImage.Error != nil || hImage.Detail.Width != 40000 || hImage.Detail.Height != 20000 {
+		t.Fatal(errors.New("large svg detail is not valid"))
+	}
+
+	if hImage.move(); hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	if stat, err := os.Stat(hImage.tempPath); err != nil || stat.Size() != hImage.Detail.Size {
+		t.Error(errors.New("sanitized svg size is not valid"))
+	}
+	hImage.Finish()
+
+	for _, option := range []Resize{{Width: 100}, {Width: 50, Height: 50, Anchor: Left}} {
+		p := &Pipeline{Steps: []Step{{Op: "resize", Width: option.Width, Height: option.Height, Anchor: option.Anchor}}}
+		hImage := p.Apply(NewHimageWithPath(f.Name()))
+		if hImage.Error != nil {
+			t.Fatal(hImage.Error)
+		}
+
+		if hImage.Detail.Mime != "image/png" || hImage.Detail.Width != option.Width || hImage.Detail.Height != 50 {
+			t.Errorf("rasterized %dx%d is not valid", hImage.Detail.Width, hImage.Detail.Height)
+		}
+		hImage.Finish()
+	}
+}
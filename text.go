@@ -0,0 +1,159 @@
+package himage
+
+import (
+	"errors"
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// Font is a parsed TrueType or OpenType font
+type Font struct {
+	font *opentype.Font
+}
+
+// ParseFont parses TTF or OTF bytes
+func ParseFont(data []byte) (*Font, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Font{font: f}, nil
+}
+
+// LoadFont ..
+func LoadFont(path string) (*Font, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFont(data)
+}
+
+// DrawText renders text over the image, lines are separated by "\n".
+// Every frame of an animated GIF is drawn on.
+func (i *Himage) DrawText(text string, option Text) *Himage {
+	if err := option.Valid(); err != nil {
+		i.Error = err
+		return i
+	}
+
+	if strings.TrimSpace(text) == "" {
+		i.Error = errors.New("text is empty")
+		return i
+	}
+
+	var label *image.NRGBA
+	return i.transform(func(src image.Image) *image.NRGBA {
+		if label == nil {
+			var err error
+			if label, err = textImage(text, option, src.Bounds().Dx()); err != nil {
+				i.Error = err
+				return nil
+			}
+		}
+		return overlay(src, label, Overlay{Anchor: option.Anchor, Margin: option.Margin})
+	})
+}
+
+// textImage renders the text with its stroke and shadow on a transparent image
+func textImage(text string, option Text, width int) (*image.NRGBA, error) {
+	face, err := opentype.NewFace(option.Font.font, &opentype.FaceOptions{
+		Size:    math.Max(1, option.Size*float64(width)),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	lines := strings.Split(text, "\n")
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	ascent := metrics.Ascent.Ceil()
+
+	textWidth := 0
+	for _, line := range lines {
+		if w := font.MeasureString(face, line).Ceil(); w > textWidth {
+			textWidth = w
+		}
+	}
+
+	pad := option.StrokeWidth
+	if option.ShadowColor != nil {
+		pad += int(math.Max(math.Abs(float64(option.ShadowOffset.X)), math.Abs(float64(option.ShadowOffset.Y))))
+	}
+
+	bounds := image.Rect(0, 0, textWidth+2*pad, lineHeight*len(lines)+2*pad)
+	if bounds.Dx()*bounds.Dy() > MAX_PIXELS {
+		return nil, errors.New("text image is too large")
+	}
+
+	mask := image.NewAlpha(bounds)
+	drawer := &font.Drawer{Dst: mask, Src: image.Opaque, Face: face}
+	for k, line := range lines {
+		drawer.Dot = fixed.P(pad, pad+ascent+k*lineHeight)
+		drawer.DrawString(line)
+	}
+
+	outline := mask
+	if option.StrokeWidth > 0 {
+		outline = dilate(mask, option.StrokeWidth)
+	}
+
+	out := image.NewNRGBA(bounds)
+	if option.ShadowColor != nil {
+		offset := image.Pt(-option.ShadowOffset.X, -option.ShadowOffset.Y)
+		draw.DrawMask(out, bounds, image.NewUniform(option.ShadowColor), image.Point{}, outline, offset, draw.Over)
+	}
+
+	if option.StrokeWidth > 0 && option.StrokeColor != nil {
+		draw.DrawMask(out, bounds, image.NewUniform(option.StrokeColor), image.Point{}, outline, image.Point{}, draw.Over)
+	}
+
+	fill := option.Color
+	if fill == nil {
+		fill = color.White
+	}
+	draw.DrawMask(out, bounds, image.NewUniform(fill), image.Point{}, mask, image.Point{}, draw.Over)
+
+	if option.Angle != 0 {
+		out = imaging.Rotate(out, option.Angle, color.Transparent)
+	}
+
+	return out, nil
+}
+
+// dilate grows the mask by radius pixels
+func dilate(mask *image.Alpha, radius int) *image.Alpha {
+	b := mask.Bounds()
+	out := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := mask.AlphaAt(x, y).A
+			if a == 0 {
+				continue
+			}
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if dx*dx+dy*dy > radius*radius {
+						continue
+					}
+					p := image.Pt(x+dx, y+dy)
+					if p.In(b) && out.AlphaAt(p.X, p.Y).A < a {
+						out.SetAlpha(p.X, p.Y, color.Alpha{A: a})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
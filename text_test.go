@@ -0,0 +1,76 @@
+package himage
+
+import (
+	"errors"
+	"golang.org/x/image/font/gofont/goregular"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestHimageDrawText(t *testing.T) {
+	f, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hImage := NewHimageWithPath(filepath.Join("test-files", "850x566.png")).
+		DrawText("himage", Text{
+			Font:        f,
+			Size:        0.1,
+			Color:       color.NRGBA{R: 255, A: 255},
+			StrokeColor: color.Black,
+			StrokeWidth: 2,
+			Anchor:      TopLeft,
+			Margin:      10,
+		})
+	if hImage.Error != nil {
+		t.Fatal(hImage.Error)
+	}
+	defer hImage.Finish()
+
+	if hImage.Detail.Width != 850 || hImage.Detail.Height != 566 {
+		t.Error(errors.New("resolution is not valid"))
+	}
+
+	label, err := textImage("himage", Text{Font: f, Size: 0.1, ShadowColor: color.Black, ShadowOffset: image.Pt(3, 3)}, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := textImage("himage", Text{Font: f, Size: 0.1, ShadowColor: color.Black, ShadowOffset: image.Pt(3, 3), Angle: 45}, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if label.Bounds().Dx() <= label.Bounds().Dy() || rotated.Bounds().Dy() <= label.Bounds().Dy() {
+		t.Error(errors.New("text image is not valid"))
+	}
+
+	opaque := false
+	for k := 3; k < len(label.Pix); k += 4 {
+		if label.Pix[k] == 255 {
+			opaque = true
+		}
+	}
+	if !opaque {
+		t.Error(errors.New("text is not rendered"))
+	}
+}
+
+func TestTextValid(t *testing.T) {
+	if (Text{Size: 0.1}).Valid() == nil {
+		t.Error(errors.New("missing font should fail"))
+	}
+
+	if _, err := ParseFont([]byte("font")); err == nil {
+		t.Error(errors.New("invalid font should fail"))
+	}
+
+	f, _ := ParseFont(goregular.TTF)
+	hImage := NewHimageWithPath(filepath.Join("test-files", "10x10.png")).DrawText(" ", Text{Font: f, Size: 0.5})
+	if hImage.Error == nil {
+		t.Error(errors.New("empty text should fail"))
+	}
+}
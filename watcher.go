@@ -0,0 +1,338 @@
+package himage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher ingests images dropped into a directory. It polls instead of
+// using file system events so it works on network shares and every OS.
+type Watcher struct {
+	// Source is the watched drop directory, hidden files and
+	// subdirectories are ignored
+	Source string
+	// Destination receives the processed images named after their sources,
+	// an existing output is not overwritten and its source is quarantined
+	Destination string
+	// Quarantine receives failed sources next to a <name>.error.json sidecar
+	Quarantine string
+	// Pipeline is applied to every image before Finish
+	Pipeline *Pipeline
+	// Naming is the output naming strategy, NamingDefault keeps the source name
+	Naming Naming
+	// Interval between scans, defaults to one second
+	Interval time.Duration
+	// Settle is how long a file must stop growing before it is processed
+	Settle time.Duration
+	// OnResult is called by Run for every processed file when set
+	OnResult func(r WatchResult)
+	// OnError is called by Run for failed scans when set, like an
+	// unreachable share, the next scan is still attempted
+	OnError func(err error)
+
+	mu    sync.Mutex
+	files map[string]*watchedFile
+}
+
+// WatchResult is the outcome of a single ingested file
+type WatchResult struct {
+	Source string
+	// Output is the written image, empty on failure
+	Output string
+	// Quarantined is the moved source of a failure
+	Quarantined string
+	Error       error
+}
+
+// WatchFailure is the error sidecar written next to a quarantined file
+type WatchFailure struct {
+	Source string    `json:"source"`
+	Error  string    `json:"error"`
+	Time   time.Time `json:"time"`
+	Detail *Detail   `json:"detail,omitempty"`
+}
+
+// watchedFile is the last seen state of a source file
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+	// done is set once processed, the file is retried only when it changes
+	done bool
+}
+
+// NewWatcher ..
+func NewWatcher(source, destination, quarantine string, pipeline *Pipeline) *Watcher {
+	return &Watcher{
+		Source:      source,
+		Destination: destination,
+		Quarantine:  quarantine,
+		Pipeline:    pipeline,
+	}
+}
+
+// Valid ..
+func (w *Watcher) Valid() error {
+	if w.Source == "" || w.Destination == "" || w.Quarantine == "" {
+		return errors.New("watcher source, destination and quarantine directories are required")
+	}
+
+	if w.Interval < 0 || w.Settle < 0 {
+		return errors.New("watcher interval and settle cannot be negative")
+	}
+
+	// outputs written into the source would be deleted with their origin
+	dirs := make(map[string]string, 3)
+	for _, d := range [][2]string{{"source", w.Source}, {"destination", w.Destination}, {"quarantine", w.Quarantine}} {
+		path, err := resolvePath(d[1])
+		if err != nil {
+			return err
+		}
+		if other, ok := dirs[path]; ok {
+			return fmt.Errorf("watcher %s and %s directories must differ", other, d[0])
+		}
+		dirs[path] = d[0]
+	}
+
+	if w.Pipeline != nil {
+		return w.Pipeline.Valid()
+	}
+
+	return nil
+}
+
+// resolvePath returns the absolute path with symlinks evaluated, missing
+// trailing elements are kept as they are
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// Run scans the source every Interval until stop is closed, scan errors
+// are reported to OnError and do not end the loop
+func (w *Watcher) Run(stop <-chan struct{}) error {
+	if err := w.Valid(); err != nil {
+		return err
+	}
+
+	interval := w.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := w.Scan()
+		if err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+		if w.OnResult != nil {
+			for _, r := range results {
+				w.OnResult(r)
+			}
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan lists the source once and processes the files whose size and
+// modification time did not change for Settle since the previous scan
+func (w *Watcher) Scan() ([]WatchResult, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(w.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.files == nil {
+		w.files = make(map[string]*watchedFile)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(entries))
+	results := make([]WatchResult, 0)
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(w.Source, entry.Name())
+		seen[path] = true
+
+		f, ok := w.files[path]
+		if !ok || f.size != entry.Size() || !f.modTime.Equal(entry.ModTime()) {
+			w.files[path] = &watchedFile{size: entry.Size(), modTime: entry.ModTime(), since: now}
+			continue
+		}
+
+		if f.done || now.Sub(f.since) < w.Settle {
+			continue
+		}
+
+		f.done = true
+		results = append(results, w.process(path))
+	}
+
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+
+	return results, nil
+}
+
+// process runs the pipeline on a settled file, the source is removed on
+// success and quarantined on failure
+func (w *Watcher) process(path string) (r WatchResult) {
+	r.Source = path
+
+	var i *Himage
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.Error = fmt.Errorf("watched file panicked: %v", rec)
+		}
+		if r.Error == nil {
+			return
+		}
+
+		quarantined, err := w.quarantine(path, i, r.Error)
+		r.Quarantined = quarantined
+		if err != nil {
+			r.Error = fmt.Errorf("%s, quarantine failed: %s", r.Error, err)
+		}
+	}()
+
+	i = NewHimageWithPath(path).SetDestination(w.Destination).SetNaming(w.Naming).RemoveOrigin(true)
+	if w.Naming == NamingDefault {
+		i.SetName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+
+	if i.Error == nil && w.Pipeline != nil {
+		w.Pipeline.Apply(i)
+	}
+
+	// sources differing only by extension would share an output
+	if i.Error == nil && w.Naming == NamingDefault {
+		output := filepath.Join(w.Destination, i.name+i.extension())
+		if _, err := os.Stat(output); err == nil {
+			i.Error = fmt.Errorf("output %s already exists", output)
+		}
+	}
+
+	_, r.Error = i.Finish()
+	r.Output = i.Output()
+	if r.Error != nil {
+		r.Output = ""
+	}
+
+	return r
+}
+
+// quarantine moves the source and writes the sidecar of cause, the
+// quarantined path is empty when the move failed
+func (w *Watcher) quarantine(path string, i *Himage, cause error) (string, error) {
+	failure := WatchFailure{Source: path, Error: cause.Error(), Time: time.Now().UTC()}
+	if i != nil && i.Detail.Mime != "" {
+		detail := i.Detail
+		failure.Detail = &detail
+	}
+
+	if err := os.MkdirAll(w.Quarantine, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	target := quarantinePath(w.Quarantine, filepath.Base(path))
+	if err := moveFile(path, target); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return target, err
+	}
+
+	return target, ioutil.WriteFile(target+".error.json", data, 0644)
+}
+
+// quarantinePath returns a free path for name, numbered when taken
+func quarantinePath(dir, name string) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	target := filepath.Join(dir, name)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			return target
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, n, ext))
+	}
+}
+
+// moveFile renames src to dst and falls back to a copy across devices
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(d, s); err != nil {
+		d.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	if err := d.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	s.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,331 @@
+package himage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestWatcher returns a watcher over fresh temp directories
+func newTestWatcher(t *testing.T) *Watcher {
+	root, err := ioutil.TempDir("", "himage-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(root)
+	})
+
+	source := filepath.Join(root, "drop")
+	if err := os.Mkdir(source, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewWatcher(source, filepath.Join(root, "out"), filepath.Join(root, "quarantine"), &Pipeline{
+		Steps: []Step{{Op: "resize", Width: 50, Height: 40}},
+	})
+}
+
+// dropFile writes data into the watched directory
+func dropFile(t *testing.T, w *Watcher, name string, data []byte) string {
+	path := filepath.Join(w.Source, name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readTestFile ..
+func readTestFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("test-files", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestWatcherScan(t *testing.T) {
+	w := newTestWatcher(t)
+	source := dropFile(t, w, "photo.jpeg", readTestFile(t, "640x426.jpeg"))
+	dropFile(t, w, ".upload.part", []byte("partial"))
+	if err := os.Mkdir(filepath.Join(w.Source, "nested"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err := w.Scan(); err != nil || len(results) != 0 {
+		t.Fatal(errors.New("first scan should only record files"))
+	}
+
+	results, err := w.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatal(errors.New("scan result count is not valid"))
+	}
+
+	r := results[0]
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	if r.Source != source || r.Output != filepath.Join(w.Destination, "photo.jpg") || r.Quarantined != "" {
+		t.Error(errors.New("scan result is not valid"))
+	}
+
+	o := NewHimageWithPath(r.Output)
+	if o.Error != nil || o.Detail.Width != 50 || o.Detail.Height != 40 {
+		t.Error(errors.New("watched output is not valid"))
+	}
+
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Error(errors.New("processed source should be removed"))
+	}
+
+	if results, err := w.Scan(); err != nil || len(results) != 0 {
+		t.Error(errors.New("processed files should not be scanned again"))
+	}
+}
+
+func TestWatcherGrowing(t *testing.T) {
+	w := newTestWatcher(t)
+	data := readTestFile(t, "10x10.png")
+	dropFile(t, w, "scan.png", data[:len(data)/2])
+
+	if results, _ := w.Scan(); len(results) != 0 {
+		t.Fatal(errors.New("new file should not be processed"))
+	}
+
+	dropFile(t, w, "scan.png", data)
+	if results, _ := w.Scan(); len(results) != 0 {
+		t.Fatal(errors.New("growing file should not be processed"))
+	}
+
+	results, _ := w.Scan()
+	if len(results) != 1 || results[0].Error != nil {
+		t.Error(errors.New("settled file should be processed"))
+	}
+}
+
+func TestWatcherSettle(t *testing.T) {
+	w := newTestWatcher(t)
+	w.Settle = 100 * time.Millisecond
+	dropFile(t, w, "photo.png", readTestFile(t, "10x10.png"))
+
+	w.Scan()
+	if results, _ := w.Scan(); len(results) != 0 {
+		t.Fatal(errors.New("file should wait for the settle time"))
+	}
+
+	time.Sleep(w.Settle)
+	if results, _ := w.Scan(); len(results) != 1 {
+		t.Error(errors.New("file should be processed after the settle time"))
+	}
+}
+
+func TestWatcherQuarantine(t *testing.T) {
+	w := newTestWatcher(t)
+	for _, want := range []string{"broken.png", "broken-1.png"} {
+		dropFile(t, w, "broken.png", []byte("not an image"))
+		w.Scan()
+		results, err := w.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Error == nil {
+			t.Fatal(errors.New("broken file should fail"))
+		}
+
+		r := results[0]
+		if r.Output != "" || r.Quarantined != filepath.Join(w.Quarantine, want) {
+			t.Fatal(errors.New("quarantine path is not valid"))
+		}
+
+		if _, err := os.Stat(r.Source); !os.IsNotExist(err) {
+			t.Error(errors.New("failed source should be moved"))
+		}
+
+		data, err := ioutil.ReadFile(r.Quarantined + ".error.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var failure WatchFailure
+		if err := json.Unmarshal(data, &failure); err != nil {
+			t.Fatal(err)
+		}
+		if failure.Source != r.Source || failure.Error != r.Error.Error() || failure.Time.IsZero() {
+			t.Error(errors.New("error sidecar is not valid"))
+		}
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	w := newTestWatcher(t)
+	w.Interval = 10 * time.Millisecond
+	dropFile(t, w, "photo.png", readTestFile(t, "10x10.png"))
+
+	received := make(chan WatchResult, 1)
+	w.OnResult = func(r WatchResult) {
+		received <- r
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- w.Run(stop)
+	}()
+
+	select {
+	case r := <-received:
+		if r.Error != nil {
+			t.Error(r.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error(errors.New("watcher did not process the file"))
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatcherValid(t *testing.T) {
+	if err := NewWatcher("drop", "", "quarantine", nil).Run(nil); err == nil {
+		t.Error(errors.New("missing destination is not valid"))
+	}
+
+	w := NewWatcher("drop", "out", "quarantine", &Pipeline{Steps: []Step{{Op: "unknown"}}})
+	if err := w.Valid(); err == nil {
+		t.Error(errors.New("invalid pipeline is not valid"))
+	}
+
+	w = NewWatcher("drop", "out", "quarantine", nil)
+	w.Settle = -time.Second
+	if err := w.Valid(); err == nil {
+		t.Error(errors.New("negative settle is not valid"))
+	}
+
+	for _, w := range []*Watcher{
+		NewWatcher("drop", "drop/", "quarantine", nil),
+		NewWatcher("drop", "out", "./drop", nil),
+		NewWatcher("drop", "out", "out/../out", nil),
+	} {
+		if err := w.Valid(); err == nil {
+			t.Errorf("%s, %s and %s directories are not valid", w.Source, w.Destination, w.Quarantine)
+		}
+	}
+}
+
+func TestWatcherRunScanError(t *testing.T) {
+	w := newTestWatcher(t)
+	w.Interval = 10 * time.Millisecond
+	if err := os.Remove(w.Source); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := make(chan error, 1)
+	w.OnError = func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+	received := make(chan WatchResult, 1)
+	w.OnResult = func(r WatchResult) {
+		received <- r
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- w.Run(stop)
+	}()
+
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal(errors.New("scan error was not reported"))
+	}
+
+	if err := os.Mkdir(w.Source, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	dropFile(t, w, "photo.png", readTestFile(t, "10x10.png"))
+
+	select {
+	case r := <-received:
+		if r.Error != nil {
+			t.Error(r.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error(errors.New("watcher should keep polling after a scan error"))
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatcherValidSymlink(t *testing.T) {
+	root, err := ioutil.TempDir("", "himage-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	source := filepath.Join(root, "drop")
+	if err := os.Mkdir(source, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(source, link); err != nil {
+		t.Skip(err)
+	}
+
+	if err := NewWatcher(source, link, filepath.Join(root, "quarantine"), nil).Valid(); err == nil {
+		t.Error(errors.New("symlinked destination is not valid"))
+	}
+
+	if err := NewWatcher(link, filepath.Join(source, "out", ".."), filepath.Join(root, "quarantine"), nil).Valid(); err == nil {
+		t.Error(errors.New("symlinked source is not valid"))
+	}
+
+	if err := NewWatcher(link, filepath.Join(root, "out"), filepath.Join(root, "quarantine"), nil).Valid(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatcherOutputCollision(t *testing.T) {
+	w := newTestWatcher(t)
+	w.Pipeline.Steps = []Step{{Op: "convert", Format: "png"}}
+	jpeg := dropFile(t, w, "a.jpeg", readTestFile(t, "640x426.jpeg"))
+	png := dropFile(t, w, "a.png", readTestFile(t, "10x10.png"))
+
+	w.Scan()
+	results, err := w.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatal(errors.New("scan result count is not valid"))
+	}
+
+	if results[0].Source != jpeg || results[0].Error != nil || results[0].Output != filepath.Join(w.Destination, "a.png") {
+		t.Error(errors.New("first output is not valid"))
+	}
+
+	if results[1].Source != png || results[1].Error == nil || results[1].Quarantined == "" {
+		t.Error(errors.New("colliding output should be quarantined"))
+	}
+
+	o := NewHimageWithPath(filepath.Join(w.Destination, "a.png"))
+	if o.Error != nil || o.Detail.Width != 640 {
+		t.Error(errors.New("first output should not be overwritten"))
+	}
+}